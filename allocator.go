@@ -0,0 +1,124 @@
+package reedsolomon
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// Allocator provides memory for shards created by the encoder.
+// It is used for every shard sized allocation: by Split for padded and parity shards,
+// by the Reconstruct functions for missing shards without enough capacity,
+// for shards returned to the caller, for temporary shards,
+// and for the block buffers of stream encoders.
+//
+// Shards returned by Split and Reconstruct are owned by the caller.
+// The encoder never frees them, so the caller can return them with Free
+// when they are no longer used, or leave them to the garbage collector.
+// Split only allocates the shards that don't fit within the capacity of the data.
+//
+// Memory the encoder allocates for its own use, like temporary shards and the
+// block buffers of stream encoders, is returned with Free when the encoder is done with it.
+// Buffers that may still be used by an abandoned concurrent read or write
+// after a context is done are never freed. This includes the read buffers
+// of ReconstructHedged, since its readers are not waited for.
+//
+// Implementations must be safe for concurrent use.
+type Allocator interface {
+	// Alloc returns a slice with length and capacity n.
+	// The content is undefined.
+	Alloc(n int) []byte
+
+	// Free returns a slice obtained from Alloc.
+	// The slice must not be used after it has been freed.
+	Free(b []byte)
+}
+
+// AllocAligned returns an Allocator that returns memory aligned
+// to 'alignment' bytes, which must be a power of 2.
+// If it isn't, 64 byte (cache line) alignment is used.
+// Use 4096 or the block size of the device for O_DIRECT IO.
+//
+// Freed slices are kept in a pool and reused for allocations of the same size.
+func AllocAligned(alignment int) Allocator {
+	if alignment <= 0 || alignment&(alignment-1) != 0 {
+		alignment = 64
+	}
+	return &alignedAllocator{alignment: alignment}
+}
+
+// alignedAllocator returns aligned memory with a pool per size.
+type alignedAllocator struct {
+	alignment int
+	mu        sync.Mutex
+	pools     map[int]*sync.Pool
+}
+
+// pool returns the pool for slices of size n.
+func (a *alignedAllocator) pool(n int) *sync.Pool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p := a.pools[n]
+	if p == nil {
+		if a.pools == nil {
+			a.pools = make(map[int]*sync.Pool)
+		}
+		p = &sync.Pool{}
+		a.pools[n] = p
+	}
+	return p
+}
+
+// Alloc returns an aligned slice of n bytes.
+func (a *alignedAllocator) Alloc(n int) []byte {
+	if n <= 0 {
+		return []byte{}
+	}
+	if b, ok := a.pool(n).Get().([]byte); ok {
+		return b
+	}
+	b := make([]byte, n+a.alignment-1)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&b[0])) & uintptr(a.alignment-1)); rem != 0 {
+		off = a.alignment - rem
+	}
+	return b[off : off+n : off+n]
+}
+
+// Free puts b back into the pool for its size.
+// Slices that are not aligned are dropped.
+func (a *alignedAllocator) Free(b []byte) {
+	b = b[:cap(b)]
+	if len(b) == 0 || uintptr(unsafe.Pointer(&b[0]))&uintptr(a.alignment-1) != 0 {
+		return
+	}
+	a.pool(len(b)).Put(b)
+}
+
+// alloc returns a slice of n bytes from the allocator of o.
+// The content is undefined.
+func (o *options) alloc(n int) []byte {
+	if o.allocator != nil {
+		return o.allocator.Alloc(n)
+	}
+	return make([]byte, n)
+}
+
+// allocZero returns a zero filled slice of n bytes from the allocator of o.
+func (o *options) allocZero(n int) []byte {
+	if o.allocator == nil {
+		return make([]byte, n)
+	}
+	b := o.allocator.Alloc(n)
+	for i := range b {
+		b[i] = 0
+	}
+	return b
+}
+
+// free returns b to the allocator of o, if any.
+// 'b' must have been returned by alloc.
+func (o *options) free(b []byte) {
+	if o.allocator != nil {
+		o.allocator.Free(b[:cap(b)])
+	}
+}
@@ -0,0 +1,184 @@
+package reedsolomon
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func isAligned(b []byte, alignment int) bool {
+	return len(b) > 0 && uintptr(unsafe.Pointer(&b[0]))%uintptr(alignment) == 0
+}
+
+// countingAllocator counts outstanding allocations.
+type countingAllocator struct {
+	Allocator
+	mu          sync.Mutex
+	outstanding int
+}
+
+func (c *countingAllocator) Alloc(n int) []byte {
+	c.mu.Lock()
+	c.outstanding++
+	c.mu.Unlock()
+	return c.Allocator.Alloc(n)
+}
+
+func (c *countingAllocator) Free(b []byte) {
+	c.mu.Lock()
+	c.outstanding--
+	c.mu.Unlock()
+	c.Allocator.Free(b)
+}
+
+func TestAllocAligned(t *testing.T) {
+	for _, alignment := range []int{64, 512, 4096} {
+		a := AllocAligned(alignment)
+		for _, n := range []int{1, 63, 64, 1000, 4096, 10000} {
+			b := a.Alloc(n)
+			if len(b) != n || cap(b) != n {
+				t.Fatalf("got len %d, cap %d, want %d", len(b), cap(b), n)
+			}
+			if !isAligned(b, alignment) {
+				t.Fatalf("alignment %d, size %d: not aligned", alignment, n)
+			}
+			a.Free(b)
+		}
+	}
+
+	enc, err := New(5, 3, testOptions(WithAllocator(AllocAligned(64)))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{1, 7, 100, 320, 1000, 10001} {
+		data := make([]byte, size)
+		fillRandom(data)
+		shards, err := enc.Split(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		perShard := len(shards[0])
+		for i, s := range shards {
+			if len(s) != perShard {
+				t.Fatalf("size %d: shard %d has size %d, want %d", size, i, len(s), perShard)
+			}
+			if i*perShard+perShard > size && !isAligned(s, 64) {
+				t.Fatalf("size %d: allocated shard %d is not aligned", size, i)
+			}
+		}
+		if err := enc.Encode(shards); err != nil {
+			t.Fatal(err)
+		}
+		ok, err := enc.Verify(shards)
+		if err != nil || !ok {
+			t.Fatal("verification failed", err)
+		}
+		shards[0], shards[6] = nil, nil
+		if err := enc.Reconstruct(shards); err != nil {
+			t.Fatal(err)
+		}
+		if !isAligned(shards[0], 64) || !isAligned(shards[6], 64) {
+			t.Fatalf("size %d: reconstructed shards are not aligned", size)
+		}
+		var buf bytes.Buffer
+		if err := enc.Join(&buf, shards, size); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("size %d: joined data mismatch", size)
+		}
+	}
+
+	// When data is allocated with the allocator and the shard size
+	// is a multiple of the alignment, all shards are aligned.
+	a := AllocAligned(64)
+	enc, err = New(4, 2, testOptions(WithAllocator(a))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := a.Alloc(4 * 128)
+	fillRandom(data)
+	shards, err := enc.Split(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range shards {
+		if !isAligned(s, 64) {
+			t.Fatalf("shard %d is not aligned", i)
+		}
+	}
+	if &shards[0][0] != &data[0] {
+		t.Fatal("data was copied")
+	}
+
+	// Stream block buffers.
+	stream, err := NewStream(4, 2, WithAllocator(a), WithStreamBlockSize(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range stream.(*rsStream).createSlice() {
+		if !isAligned(b, 64) {
+			t.Fatalf("stream buffer %d is not aligned", i)
+		}
+	}
+	data = make([]byte, 10000)
+	fillRandom(data)
+	parity := make([]io.Writer, 2)
+	pbufs := make([]*bytes.Buffer, 2)
+	for i := range parity {
+		pbufs[i] = &bytes.Buffer{}
+		parity[i] = pbufs[i]
+	}
+	dataShards, err := enc.Split(append([]byte(nil), data...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(dataShards); err != nil {
+		t.Fatal(err)
+	}
+	readers := make([]io.Reader, 4)
+	for i := range readers {
+		readers[i] = bytes.NewReader(dataShards[i])
+	}
+	if err := stream.Encode(readers, parity); err != nil {
+		t.Fatal(err)
+	}
+	for i := range pbufs {
+		if !bytes.Equal(pbufs[i].Bytes(), dataShards[4+i]) {
+			t.Fatalf("stream parity %d mismatch", i)
+		}
+	}
+
+	// Stream buffers are returned to the allocator.
+	ca := &countingAllocator{Allocator: AllocAligned(64)}
+	stream, err = NewStream(4, 2, WithAllocator(ca), WithStreamBlockSize(1000), WithStreamPipeline(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range readers {
+		readers[i] = bytes.NewReader(dataShards[i])
+	}
+	if err := stream.Encode(readers, toWriters(emptyBuffers(2))); err != nil {
+		t.Fatal(err)
+	}
+	if ca.outstanding != 0 {
+		t.Fatalf("%d stream buffers were not freed", ca.outstanding)
+	}
+
+	// Temporary shards are returned to the allocator.
+	enc, err = New(4, 2, testOptions(WithAllocator(ca))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := enc.Verify(dataShards); err != nil || !ok {
+		t.Fatal("verification failed", err)
+	}
+	if ok, err := enc.(VarLenEncoder).VerifyVarLen(dataShards); err != nil || !ok {
+		t.Fatal("verification failed", err)
+	}
+	if ca.outstanding != 0 {
+		t.Fatalf("%d temporary shards were not freed", ca.outstanding)
+	}
+}
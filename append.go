@@ -0,0 +1,125 @@
+package reedsolomon
+
+// AppendEncoder keeps parity up to date for data shards that grow by appending.
+//
+// Each data shard has its own length, and shorter data shards are treated as
+// if they were extended with zeros up to the length of the parity,
+// which is the length of the longest data shard.
+// When bytes are appended to a data shard, only the matching range of the
+// parity is updated, so the cost is proportional to the appended bytes.
+//
+// The data itself is not stored by the AppendEncoder.
+// To verify or reconstruct with an Encoder, extend all data shards
+// with zeros to the length of the parity.
+//
+// An AppendEncoder is not safe for concurrent use.
+type AppendEncoder struct {
+	d       *DeltaCoder
+	lengths []int
+	parity  [][]byte
+}
+
+// NewAppendEncoder creates an AppendEncoder for the encoder with the given configuration,
+// with all data shards empty.
+// The configuration can be obtained from Encoder.Config.
+// Options can be supplied to control performance, but the matrix is always taken from the configuration.
+func NewAppendEncoder(c Config, opts ...Option) (*AppendEncoder, error) {
+	d, err := NewDeltaCoder(c, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &AppendEncoder{
+		d:       d,
+		lengths: make([]int, c.DataShards),
+		parity:  make([][]byte, c.ParityShards),
+	}, nil
+}
+
+// Restore sets the state of the encoder, for example after a restart.
+// 'lengths' contains the length of every data shard, and 'parity' the parity shards
+// matching data shards of these lengths. The parity must be as long as the longest data shard.
+// The encoder keeps using the parity slices, which will be appended to.
+func (a *AppendEncoder) Restore(lengths []int, parity [][]byte) error {
+	if len(lengths) != a.d.dataShards || len(parity) != a.d.parityShards {
+		return ErrTooFewShards
+	}
+	size := 0
+	for _, l := range lengths {
+		if l < 0 {
+			return ErrShardSize
+		}
+		if l > size {
+			size = l
+		}
+	}
+	for _, p := range parity {
+		if len(p) != size {
+			return ErrShardSize
+		}
+	}
+	copy(a.lengths, lengths)
+	copy(a.parity, parity)
+	return nil
+}
+
+// Append adds data to the end of data shard idx and updates the parity.
+// Only the range of the parity that data is appended to is calculated.
+// If the data shard becomes the longest, the parity is extended.
+func (a *AppendEncoder) Append(idx int, data []byte) error {
+	if idx < 0 || idx >= a.d.dataShards {
+		return ErrInvShardNum
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	start := a.lengths[idx]
+	end := start + len(data)
+	if end > len(a.parity[0]) {
+		for i := range a.parity {
+			a.parity[i] = growZero(a.parity[i], end)
+		}
+	}
+	for i := range a.parity {
+		galMulSliceXor(a.d.parity[i][idx], data, a.parity[i][start:end], &a.d.o)
+	}
+	a.lengths[idx] = end
+	return nil
+}
+
+// Len returns the length of data shard idx.
+func (a *AppendEncoder) Len(idx int) int {
+	if idx < 0 || idx >= a.d.dataShards {
+		return 0
+	}
+	return a.lengths[idx]
+}
+
+// Lengths returns the length of every data shard.
+func (a *AppendEncoder) Lengths() []int {
+	return append([]int(nil), a.lengths...)
+}
+
+// Parity returns the parity shards, which are as long as the longest data shard.
+// The returned slices are only valid until the next call to Append.
+func (a *AppendEncoder) Parity() [][]byte {
+	return append([][]byte(nil), a.parity...)
+}
+
+// growZero extends b to length n, with the new bytes set to zero.
+func growZero(b []byte, n int) []byte {
+	if n <= cap(b) {
+		old := len(b)
+		b = b[:n]
+		for i := old; i < n; i++ {
+			b[i] = 0
+		}
+		return b
+	}
+	newCap := 2 * cap(b)
+	if newCap < n {
+		newCap = n
+	}
+	nb := make([]byte, n, newCap)
+	copy(nb, b)
+	return nb
+}
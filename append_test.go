@@ -0,0 +1,98 @@
+package reedsolomon
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestAppendEncoder(t *testing.T) {
+	const dataShards, parityShards = 5, 3
+	enc, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAppendEncoder(enc.(ConfigEncoder).Config(), testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(0))
+	data := make([][]byte, dataShards)
+
+	// check encodes the zero extended data and compares with the appended parity.
+	check := func() {
+		t.Helper()
+		parity := a.Parity()
+		size := len(parity[0])
+		shards := make([][]byte, dataShards+parityShards)
+		for i := range data {
+			if a.Len(i) != len(data[i]) {
+				t.Fatalf("shard %d: got length %d, want %d", i, a.Len(i), len(data[i]))
+			}
+			if len(data[i]) > size {
+				t.Fatalf("parity size %d smaller than shard %d size %d", size, i, len(data[i]))
+			}
+			shards[i] = make([]byte, size)
+			copy(shards[i], data[i])
+		}
+		for i := range parity {
+			shards[dataShards+i] = make([]byte, size)
+		}
+		if err := enc.Encode(shards); err != nil {
+			t.Fatal(err)
+		}
+		for i := range parity {
+			if !bytes.Equal(parity[i], shards[dataShards+i]) {
+				t.Fatalf("parity %d mismatch", i)
+			}
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		idx := rng.Intn(dataShards)
+		b := make([]byte, rng.Intn(500))
+		rng.Read(b)
+		if err := a.Append(idx, b); err != nil {
+			t.Fatal(err)
+		}
+		data[idx] = append(data[idx], b...)
+		if i%10 == 0 {
+			check()
+		}
+	}
+	check()
+
+	// Continue from a saved state.
+	saved := a.Parity()
+	for i := range saved {
+		saved[i] = append([]byte(nil), saved[i]...)
+	}
+	b, err := NewAppendEncoder(enc.(ConfigEncoder).Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Restore(a.Lengths(), saved); err != nil {
+		t.Fatal(err)
+	}
+	a = b
+	for i := 0; i < 20; i++ {
+		idx := rng.Intn(dataShards)
+		buf := make([]byte, rng.Intn(500))
+		rng.Read(buf)
+		if err := a.Append(idx, buf); err != nil {
+			t.Fatal(err)
+		}
+		data[idx] = append(data[idx], buf...)
+	}
+	check()
+
+	if err := a.Append(dataShards, []byte{1}); err != ErrInvShardNum {
+		t.Errorf("expected %v, got %v", ErrInvShardNum, err)
+	}
+	if err := a.Restore(make([]int, dataShards), [][]byte{{1}, nil, nil}); err != ErrShardSize {
+		t.Errorf("expected %v, got %v", ErrShardSize, err)
+	}
+	if err := a.Restore(make([]int, dataShards-1), make([][]byte, parityShards)); err != ErrTooFewShards {
+		t.Errorf("expected %v, got %v", ErrTooFewShards, err)
+	}
+}
@@ -0,0 +1,103 @@
+package reedsolomon
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// EncodeBatch encodes parity for several stripes, like calling Encode for each.
+// Each stripe is a set of shards in the same format as Encode,
+// but different stripes may have different shard sizes.
+//
+// The stripes are checked before any encoding is done, and the setup
+// is shared between stripes. Stripes are encoded in parallel,
+// with each stripe encoded by a single goroutine, so this is well
+// suited for many small stripes. For stripes with large shards
+// Encode will usually be faster.
+//
+// If WithSkipZeroBlocks is enabled, zero blocks are skipped like in Encode.
+//
+// If a stripe is invalid, the error is returned and no stripes are encoded.
+func (r *reedSolomon) EncodeBatch(stripes [][][]byte) error {
+	for _, shards := range stripes {
+		if len(shards) != r.Shards {
+			return ErrTooFewShards
+		}
+		if err := checkShards(shards, false); err != nil {
+			return err
+		}
+	}
+	if r.ParityShards == 0 || len(stripes) == 0 {
+		return nil
+	}
+
+	// The AVX2 matrix only depends on the encoder, so create it once.
+	var avx2Matrix []byte
+	if r.canAVX2C(avx2CodeGenMinSize, r.DataShards, r.ParityShards) {
+		avx2Matrix = genAvx2Matrix(r.parity, r.DataShards, 0, r.ParityShards, r.mPool.Get().([]byte))
+		defer r.mPool.Put(avx2Matrix)
+	}
+
+	workers := r.o.maxGoroutines
+	if procs := runtime.GOMAXPROCS(0); workers > procs {
+		workers = procs
+	}
+	if workers > len(stripes) {
+		workers = len(stripes)
+	}
+	if workers <= 1 {
+		for _, shards := range stripes {
+			r.encodeStripe(shards, avx2Matrix)
+		}
+		return nil
+	}
+
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				idx := atomic.AddInt64(&next, 1)
+				if idx >= int64(len(stripes)) {
+					return
+				}
+				r.encodeStripe(stripes[idx], avx2Matrix)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// encodeStripe encodes a single stripe without using goroutines.
+// If avx2Matrix is not nil, it must be the AVX2 matrix of all parity rows.
+func (r *reedSolomon) encodeStripe(shards [][]byte, avx2Matrix []byte) {
+	inputs := shards[:r.DataShards]
+	outputs := shards[r.DataShards:]
+	byteCount := len(shards[0])
+	if r.o.skipZeroBlocks {
+		newSparseCoder(r.parity, inputs, outputs, r.codeSomeShardsSerial).codeAll(byteCount, true)
+		return
+	}
+	if avx2Matrix == nil || byteCount < avx2CodeGenMinSize {
+		r.codeSomeShardsSerial(r.parity, inputs, outputs, byteCount)
+		return
+	}
+	start := galMulSlicesAvx2(avx2Matrix, inputs, outputs, 0, byteCount)
+	if start == byteCount {
+		return
+	}
+	// Encode the remainder.
+	in := make([][]byte, len(inputs))
+	for i := range in {
+		in[i] = inputs[i][start:]
+	}
+	out := make([][]byte, len(outputs))
+	for i := range out {
+		out[i] = outputs[i][start:]
+	}
+	r.codeSomeShardsSerial(r.parity, in, out, byteCount-start)
+}
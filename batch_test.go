@@ -0,0 +1,99 @@
+package reedsolomon
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestEncodeBatch(t *testing.T) {
+	sizes := []int{1, 10, 63, 64, 100, 1000, 5000, 70000}
+	for _, shards := range [][2]int{{10, 3}, {4, 1}, {20, 12}} {
+		for _, opts := range append(testOpts(), []Option{WithMaxGoroutines(1)}, []Option{WithSkipZeroBlocks(true)}) {
+			enc, err := New(shards[0], shards[1], testOptions(opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stripes, want [][][]byte
+			for i := 0; i < 50; i++ {
+				size := sizes[i%len(sizes)]
+				stripe := make([][]byte, shards[0]+shards[1])
+				for j := range stripe {
+					stripe[j] = make([]byte, size)
+					// Leave some data shards zero, and parity with old content.
+					if j >= shards[0] || (i+j)%3 != 0 {
+						fillRandom(stripe[j])
+					}
+				}
+				ref := make([][]byte, len(stripe))
+				for j := range stripe {
+					ref[j] = append([]byte(nil), stripe[j]...)
+				}
+				if err := enc.Encode(ref); err != nil {
+					t.Fatal(err)
+				}
+				stripes = append(stripes, stripe)
+				want = append(want, ref)
+			}
+			if err := enc.(BatchEncoder).EncodeBatch(stripes); err != nil {
+				t.Fatal(err)
+			}
+			for i := range stripes {
+				for j := range stripes[i] {
+					if !bytes.Equal(stripes[i][j], want[i][j]) {
+						t.Fatalf("%v: stripe %d (size %d), shard %d mismatch", shards, i, len(stripes[i][0]), j)
+					}
+				}
+			}
+		}
+	}
+
+	enc, err := New(5, 3, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.(BatchEncoder).EncodeBatch(nil); err != nil {
+		t.Error(err)
+	}
+	bad := [][][]byte{make([][]byte, 8), make([][]byte, 7)}
+	for i := range bad[0] {
+		bad[0][i] = make([]byte, 10)
+	}
+	if err := enc.(BatchEncoder).EncodeBatch(bad); err != ErrTooFewShards {
+		t.Errorf("expected %v, got %v", ErrTooFewShards, err)
+	}
+	bad[1] = make([][]byte, 8)
+	if err := enc.(BatchEncoder).EncodeBatch(bad); err != ErrShardNoData {
+		t.Errorf("expected %v, got %v", ErrShardNoData, err)
+	}
+}
+
+func benchmarkEncodeBatch(b *testing.B, dataShards, parityShards, shardSize, stripes int) {
+	enc, err := New(dataShards, parityShards, testOptions(WithAutoGoroutines(shardSize))...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	batch := make([][][]byte, stripes)
+	for i := range batch {
+		batch[i] = make([][]byte, dataShards+parityShards)
+		for j := range batch[i] {
+			batch[i][j] = make([]byte, shardSize)
+			fillRandom(batch[i][j])
+		}
+	}
+	b.SetBytes(int64(shardSize * dataShards * stripes))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := enc.(BatchEncoder).EncodeBatch(batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeBatch(b *testing.B) {
+	for _, size := range []int{256, 4096} {
+		b.Run(fmt.Sprintf("10x4x%dx1000", size), func(b *testing.B) {
+			benchmarkEncodeBatch(b, 10, 4, size, 1000)
+		})
+	}
+}
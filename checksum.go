@@ -0,0 +1,239 @@
+package reedsolomon
+
+import (
+	"errors"
+	"hash/crc32"
+)
+
+// Checksums contains CRC32C (Castagnoli) checksums of every block of every shard.
+// Each shard is divided into blocks of BlockSize bytes, the last block of a shard
+// may be shorter.
+type Checksums struct {
+	// BlockSize is the number of bytes covered by each checksum.
+	BlockSize int
+
+	// Sums contains the checksums for each block of each shard, indexed as [shard][block].
+	// A nil entry means the checksums of the shard are not known.
+	Sums [][]uint32
+}
+
+// ErrInvalidBlockSize is returned if the checksum block size is zero or negative.
+var ErrInvalidBlockSize = errors.New("invalid checksum block size")
+
+// ErrChecksumMismatch is returned by ReconstructVerified if a block
+// still doesn't match its checksum after reconstruction.
+var ErrChecksumMismatch = errors.New("block checksum mismatch after reconstruction")
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// NewChecksums calculates checksums of every block of blockSize bytes of the shards.
+// Shards that are nil or zero-length will have no checksums.
+func NewChecksums(shards [][]byte, blockSize int) (*Checksums, error) {
+	if blockSize <= 0 {
+		return nil, ErrInvalidBlockSize
+	}
+	c := &Checksums{
+		BlockSize: blockSize,
+		Sums:      make([][]uint32, len(shards)),
+	}
+	for i, shard := range shards {
+		c.Update(i, shard)
+	}
+	return c, nil
+}
+
+// Update recalculates the checksums of a single shard.
+// If the shard is nil or zero-length, the checksums are removed.
+func (c *Checksums) Update(idx int, shard []byte) {
+	if len(shard) == 0 {
+		c.Sums[idx] = nil
+		return
+	}
+	sums := c.Sums[idx][:0]
+	for start := 0; start < len(shard); start += c.BlockSize {
+		sums = append(sums, crc32.Checksum(shard[start:c.blockEnd(start, len(shard))], crc32cTable))
+	}
+	c.Sums[idx] = sums
+}
+
+// BadBlocks returns the indices of the blocks of shard idx that are
+// missing or don't match their checksums.
+// If there are no checksums for the shard, nil is returned.
+func (c *Checksums) BadBlocks(idx int, shard []byte) []int {
+	sums := c.Sums[idx]
+	if sums == nil {
+		return nil
+	}
+	var bad []int
+	for b, sum := range sums {
+		if !c.checkBlock(b, sum, shard) {
+			bad = append(bad, b)
+		}
+	}
+	return bad
+}
+
+// blockEnd returns the end of the block starting at start.
+func (c *Checksums) blockEnd(start, size int) int {
+	if start+c.BlockSize > size {
+		return size
+	}
+	return start + c.BlockSize
+}
+
+// checkBlock returns whether block b of shard is present and matches sum.
+func (c *Checksums) checkBlock(b int, sum uint32, shard []byte) bool {
+	start := b * c.BlockSize
+	if start >= len(shard) {
+		return false
+	}
+	return crc32.Checksum(shard[start:c.blockEnd(start, len(shard))], crc32cTable) == sum
+}
+
+// EncodeChecksums encodes parity like Encode and returns checksums
+// of every block of blockSize bytes of all data and parity shards.
+// The checksums are calculated for a chunk of blocks just after it has been encoded,
+// so the most recently coded part may still be in cache.
+func (r *reedSolomon) EncodeChecksums(shards [][]byte, blockSize int) (*Checksums, error) {
+	if blockSize <= 0 {
+		return nil, ErrInvalidBlockSize
+	}
+	if len(shards) != r.Shards {
+		return nil, ErrTooFewShards
+	}
+	err := checkShards(shards, false)
+	if err != nil {
+		return nil, err
+	}
+	size := len(shards[0])
+	blocks := (size + blockSize - 1) / blockSize
+	c := &Checksums{
+		BlockSize: blockSize,
+		Sums:      make([][]uint32, r.Shards),
+	}
+	for i := range c.Sums {
+		c.Sums[i] = make([]uint32, blocks)
+	}
+
+	// Encode in chunks of whole blocks, so each chunk is large enough
+	// to use all goroutines. Each goroutine codes a cache sized part
+	// of the chunk, but the chunk as a whole may be larger than the cache.
+	perChunk := r.o.perRound * r.o.maxGoroutines
+	perChunk -= perChunk % blockSize
+	if perChunk < blockSize {
+		perChunk = blockSize
+	}
+	input := make([][]byte, r.DataShards)
+	output := make([][]byte, r.ParityShards)
+	for start := 0; start < size; start += perChunk {
+		end := start + perChunk
+		if end > size {
+			end = size
+		}
+		for i := range input {
+			input[i] = shards[i][start:end]
+		}
+		for i := range output {
+			output[i] = shards[r.DataShards+i][start:end]
+		}
+		r.codeSomeShards(r.parity, input, output, end-start)
+		for i, shard := range shards {
+			for b := start; b < end; b += blockSize {
+				c.Sums[i][b/blockSize] = crc32.Checksum(shard[b:c.blockEnd(b, size)], crc32cTable)
+			}
+		}
+	}
+	return c, nil
+}
+
+// ReconstructVerified will recreate missing shards and blocks that don't match their checksums.
+//
+// Each block of each shard is checked against the checksums.
+// Blocks that do not match, or are missing, are treated as erasures and
+// recreated from the blocks of the other shards that do match.
+// Corrupted blocks of present shards are overwritten in place.
+// Missing shards are allocated as in Reconstruct.
+// Shards without checksums are assumed to be correct if present.
+//
+// If more than ParityShards blocks at the same offset are bad,
+// ErrTooFewShards is returned. Blocks that have already been repaired
+// will remain so.
+func (r *reedSolomon) ReconstructVerified(shards [][]byte, sums *Checksums) error {
+	if len(shards) != r.Shards || sums == nil || len(sums.Sums) != r.Shards {
+		return ErrTooFewShards
+	}
+	if sums.BlockSize <= 0 {
+		return ErrInvalidBlockSize
+	}
+	err := checkShards(shards, true)
+	if err != nil {
+		return err
+	}
+	size := shardSize(shards)
+	missing := make([]bool, r.Shards)
+	for i := range shards {
+		if len(shards[i]) == 0 {
+			if cap(shards[i]) >= size {
+				shards[i] = shards[i][:size]
+			} else {
+				shards[i] = r.o.alloc(size)
+			}
+			missing[i] = true
+		}
+	}
+
+	sub := make([][]byte, r.Shards)
+	bad := make([]int, 0, r.Shards)
+	for start := 0; start < size; start += sums.BlockSize {
+		end := sums.blockEnd(start, size)
+		b := start / sums.BlockSize
+		bad = bad[:0]
+		for i, shard := range shards {
+			sub[i] = shard[start:end]
+			s := sums.Sums[i]
+			if missing[i] || (s != nil && (b >= len(s) || !sums.checkBlock(b, s[b], shard))) {
+				// Reconstruct will write to the existing memory.
+				sub[i] = sub[i][:0]
+				bad = append(bad, i)
+			}
+		}
+		if len(bad) == 0 {
+			continue
+		}
+		if len(bad) > r.ParityShards {
+			return ErrTooFewShards
+		}
+		err = r.reconstruct(sub, false)
+		if err != nil {
+			return err
+		}
+		for _, i := range bad {
+			s := sums.Sums[i]
+			if b < len(s) && !sums.checkBlock(b, s[b], shards[i]) {
+				return ErrChecksumMismatch
+			}
+		}
+	}
+	return nil
+}
+
+// SplitChecksums splits data into shards like Split, encodes the parity
+// and returns the shards with the checksums of every block of blockSize bytes
+// of all data and parity shards, like EncodeChecksums.
+//
+// The checksums are calculated while encoding, so the padding
+// added by Split is included in the checksums of the last data shards.
+func (r *reedSolomon) SplitChecksums(data []byte, blockSize int) ([][]byte, *Checksums, error) {
+	if blockSize <= 0 {
+		return nil, nil, ErrInvalidBlockSize
+	}
+	shards, err := r.Split(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	sums, err := r.EncodeChecksums(shards, blockSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return shards, sums, nil
+}
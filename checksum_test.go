@@ -0,0 +1,134 @@
+package reedsolomon
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	for _, o := range testOpts() {
+		testChecksums(t, o...)
+	}
+}
+
+func testChecksums(t *testing.T, o ...Option) {
+	const dataShards, parityShards = 6, 3
+	const blockSize = 1000
+	r, err := New(dataShards, parityShards, testOptions(o...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{1, blockSize, 10*blockSize + 123, 100003} {
+		shards := randomBytes(dataShards+parityShards, size)
+		sums, err := r.(ChecksumEncoder).EncodeChecksums(shards, blockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := r.Verify(shards)
+		if err != nil || !ok {
+			t.Fatal("verification failed", err)
+		}
+		want, err := NewChecksums(shards, blockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sums, want) {
+			t.Fatal("checksums do not match NewChecksums")
+		}
+		orig := make([][]byte, len(shards))
+		for i := range shards {
+			orig[i] = append([]byte(nil), shards[i]...)
+		}
+
+		// Corrupt different blocks in up to parityShards shards per block,
+		// and remove one shard entirely.
+		blocks := len(sums.Sums[0])
+		shards[0][0]++
+		shards[7][size/2]++
+		if blocks > 1 {
+			shards[1][size-1]++
+		}
+		if blocks > 2 {
+			shards[2][blockSize]++
+			shards[3][blockSize+1]++
+		}
+		shards[5] = nil
+		for i, shard := range shards {
+			if i == 5 {
+				continue
+			}
+			if i < 4 && len(sums.BadBlocks(i, shard)) == 0 && !bytes.Equal(shard, orig[i]) {
+				t.Fatalf("shard %d: corruption not detected", i)
+			}
+		}
+		err = r.(ChecksumEncoder).ReconstructVerified(shards, sums)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range shards {
+			if !bytes.Equal(shards[i], orig[i]) {
+				t.Fatalf("size %d: shard %d was not restored", size, i)
+			}
+			if len(sums.BadBlocks(i, shards[i])) != 0 {
+				t.Fatalf("size %d: shard %d still has bad blocks", size, i)
+			}
+		}
+
+		// Too many bad blocks at the same offset.
+		shards[0][0]++
+		shards[1][0]++
+		shards[2][0]++
+		shards[3][0]++
+		err = r.(ChecksumEncoder).ReconstructVerified(shards, sums)
+		if err != ErrTooFewShards {
+			t.Errorf("expected %v, got %v", ErrTooFewShards, err)
+		}
+	}
+
+	_, err = r.(ChecksumEncoder).EncodeChecksums(randomBytes(dataShards+parityShards, 10), 0)
+	if err != ErrInvalidBlockSize {
+		t.Errorf("expected %v, got %v", ErrInvalidBlockSize, err)
+	}
+}
+
+func TestSplitChecksums(t *testing.T) {
+	const dataShards, parityShards = 6, 3
+	const blockSize = 100
+	r, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 12345)
+	fillRandom(data)
+	shards, sums, err := r.(ChecksumEncoder).SplitChecksums(append([]byte(nil), data...), blockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := r.Verify(shards)
+	if err != nil || !ok {
+		t.Fatal("verification failed", err)
+	}
+	want, err := NewChecksums(shards, blockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sums, want) {
+		t.Fatal("checksums do not match NewChecksums")
+	}
+	shards[2][5]++
+	shards[4] = nil
+	if err := r.(ChecksumEncoder).ReconstructVerified(shards, sums); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := r.Join(&buf, shards, len(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("joined data mismatch")
+	}
+	if _, _, err := r.(ChecksumEncoder).SplitChecksums(data, 0); err != ErrInvalidBlockSize {
+		t.Errorf("expected %v, got %v", ErrInvalidBlockSize, err)
+	}
+}
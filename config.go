@@ -0,0 +1,262 @@
+package reedsolomon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// MatrixType identifies how the encoding matrix of an encoder was built.
+type MatrixType uint8
+
+const (
+	// MatrixVandermonde is the default matrix, derived from a Vandermonde matrix.
+	MatrixVandermonde MatrixType = iota
+	// MatrixCauchy is used with WithCauchyMatrix.
+	MatrixCauchy
+	// MatrixPAR1 is used with WithPAR1Matrix.
+	MatrixPAR1
+	// MatrixXor is used with WithFastOneParityMatrix when there is one parity shard.
+	MatrixXor
+	// MatrixCustom is used with WithCustomMatrix.
+	MatrixCustom
+)
+
+var matrixTypeNames = [...]string{
+	MatrixVandermonde: "vandermonde",
+	MatrixCauchy:      "cauchy",
+	MatrixPAR1:        "par1",
+	MatrixXor:         "xor",
+	MatrixCustom:      "custom",
+}
+
+// String returns the name of the matrix type.
+func (t MatrixType) String() string {
+	if int(t) < len(matrixTypeNames) {
+		return matrixTypeNames[t]
+	}
+	return fmt.Sprintf("MatrixType(%d)", uint8(t))
+}
+
+// MarshalText returns the name of the matrix type.
+func (t MatrixType) MarshalText() ([]byte, error) {
+	if int(t) >= len(matrixTypeNames) {
+		return nil, ErrInvalidConfig
+	}
+	return []byte(matrixTypeNames[t]), nil
+}
+
+// UnmarshalText sets the matrix type from its name.
+func (t *MatrixType) UnmarshalText(text []byte) error {
+	for i, name := range matrixTypeNames {
+		if string(text) == name {
+			*t = MatrixType(i)
+			return nil
+		}
+	}
+	return ErrInvalidConfig
+}
+
+// Config describes how an encoder was built.
+// It can be obtained from an Encoder or LRCEncoder through ConfigEncoder,
+// serialized as JSON or binary, and used to create an identical encoder
+// with NewFromConfig or NewLRCFromConfig.
+//
+// Options that only affect performance are not part of the configuration.
+type Config struct {
+	// DataShards is the number of data shards.
+	DataShards int `json:"data_shards"`
+
+	// ParityShards is the number of parity shards.
+	// For LRC encoders this is the number of global parity shards.
+	ParityShards int `json:"parity_shards"`
+
+	// LocalShards is the number of local parity shards of an LRC encoder,
+	// or 0 for regular encoders.
+	LocalShards int `json:"local_shards,omitempty"`
+
+	// Matrix is the type of encoding matrix.
+	Matrix MatrixType `json:"matrix"`
+
+	// CustomMatrix contains the parity rows of the encoding matrix
+	// if Matrix is MatrixCustom.
+	CustomMatrix [][]byte `json:"custom_matrix,omitempty"`
+
+	// Fingerprint is a hash of the shard layout and generator matrices.
+	// Encoders that produce identical output have identical fingerprints.
+	Fingerprint uint64 `json:"fingerprint"`
+}
+
+// ErrInvalidConfig is returned if a configuration cannot be decoded
+// or does not describe a valid encoder.
+var ErrInvalidConfig = errors.New("invalid encoder configuration")
+
+// ErrConfigMismatch is returned when an encoder created from a configuration
+// does not match the fingerprint of the configuration.
+var ErrConfigMismatch = errors.New("encoder does not match configuration fingerprint")
+
+const (
+	// configVersion is the first byte of the binary encoding.
+	configVersion = 1
+
+	// configHeaderSize is the size of the binary encoding without a custom matrix.
+	configHeaderSize = 1 + 2*3 + 1 + 8
+)
+
+// MarshalBinary returns a compact binary representation of the configuration.
+func (c Config) MarshalBinary() ([]byte, error) {
+	if c.DataShards < 0 || c.DataShards > 256 || c.ParityShards < 0 || c.ParityShards > 256 ||
+		c.LocalShards < 0 || c.LocalShards > 256 || int(c.Matrix) >= len(matrixTypeNames) {
+		return nil, ErrInvalidConfig
+	}
+	dst := make([]byte, configHeaderSize, configHeaderSize+len(c.CustomMatrix)*c.DataShards)
+	dst[0] = configVersion
+	binary.BigEndian.PutUint16(dst[1:], uint16(c.DataShards))
+	binary.BigEndian.PutUint16(dst[3:], uint16(c.ParityShards))
+	binary.BigEndian.PutUint16(dst[5:], uint16(c.LocalShards))
+	dst[7] = byte(c.Matrix)
+	binary.BigEndian.PutUint64(dst[8:], c.Fingerprint)
+	if c.Matrix == MatrixCustom {
+		if len(c.CustomMatrix) != c.ParityShards {
+			return nil, ErrInvalidConfig
+		}
+		for _, row := range c.CustomMatrix {
+			if len(row) != c.DataShards {
+				return nil, ErrInvalidConfig
+			}
+			dst = append(dst, row...)
+		}
+	}
+	return dst, nil
+}
+
+// UnmarshalBinary decodes a configuration created by MarshalBinary.
+func (c *Config) UnmarshalBinary(data []byte) error {
+	if len(data) < configHeaderSize || data[0] != configVersion {
+		return ErrInvalidConfig
+	}
+	dec := Config{
+		DataShards:   int(binary.BigEndian.Uint16(data[1:])),
+		ParityShards: int(binary.BigEndian.Uint16(data[3:])),
+		LocalShards:  int(binary.BigEndian.Uint16(data[5:])),
+		Matrix:       MatrixType(data[7]),
+		Fingerprint:  binary.BigEndian.Uint64(data[8:]),
+	}
+	if int(dec.Matrix) >= len(matrixTypeNames) {
+		return ErrInvalidConfig
+	}
+	data = data[configHeaderSize:]
+	if dec.Matrix == MatrixCustom {
+		if len(data) != dec.DataShards*dec.ParityShards {
+			return ErrInvalidConfig
+		}
+		dec.CustomMatrix = make([][]byte, dec.ParityShards)
+		for i := range dec.CustomMatrix {
+			dec.CustomMatrix[i] = append([]byte(nil), data[:dec.DataShards]...)
+			data = data[dec.DataShards:]
+		}
+	} else if len(data) != 0 {
+		return ErrInvalidConfig
+	}
+	*c = dec
+	return nil
+}
+
+// withConfigMatrix returns an option that selects the matrix of the configuration,
+// overriding any matrix options given before it.
+func withConfigMatrix(c Config) Option {
+	return func(o *options) {
+		o.useCauchy = c.Matrix == MatrixCauchy
+		o.usePAR1Matrix = c.Matrix == MatrixPAR1
+		o.fastOneParity = c.Matrix == MatrixXor
+		o.customMatrix = nil
+		if c.Matrix == MatrixCustom {
+			o.customMatrix = c.CustomMatrix
+		}
+	}
+}
+
+// NewFromConfig creates an encoder identical to the one the configuration was obtained from.
+// Options can be supplied to control performance, but the matrix is always taken from the configuration.
+// If the fingerprint of the created encoder doesn't match the configuration
+// ErrConfigMismatch is returned.
+func NewFromConfig(c Config, opts ...Option) (Encoder, error) {
+	if c.LocalShards != 0 || int(c.Matrix) >= len(matrixTypeNames) ||
+		(c.Matrix == MatrixCustom) != (c.CustomMatrix != nil) {
+		return nil, ErrInvalidConfig
+	}
+	enc, err := New(c.DataShards, c.ParityShards, append(opts[:len(opts):len(opts)], withConfigMatrix(c))...)
+	if err != nil {
+		return nil, err
+	}
+	if enc.(ConfigEncoder).Config().Fingerprint != c.Fingerprint {
+		return nil, ErrConfigMismatch
+	}
+	return enc, nil
+}
+
+// NewLRCFromConfig creates an LRC encoder identical to the one the configuration was obtained from.
+// If the fingerprint of the created encoder doesn't match the configuration
+// ErrConfigMismatch is returned.
+func NewLRCFromConfig(c Config, opts ...Option) (LRCEncoder, error) {
+	if c.LocalShards == 0 || c.Matrix != MatrixVandermonde {
+		return nil, ErrInvalidConfig
+	}
+	enc, err := NewLRC(c.DataShards, c.LocalShards, c.ParityShards, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if enc.(ConfigEncoder).Config().Fingerprint != c.Fingerprint {
+		return nil, ErrConfigMismatch
+	}
+	return enc, nil
+}
+
+// Config returns the configuration of the encoder.
+func (r *reedSolomon) Config() Config {
+	c := Config{
+		DataShards:   r.DataShards,
+		ParityShards: r.ParityShards,
+		Matrix:       r.matrixType,
+	}
+	if c.Matrix == MatrixCustom {
+		c.CustomMatrix = make([][]byte, r.ParityShards)
+		for i := range c.CustomMatrix {
+			c.CustomMatrix[i] = append([]byte(nil), r.parity[i]...)
+		}
+	}
+	c.Fingerprint = fingerprint(c.DataShards, c.ParityShards, c.LocalShards, r.m)
+	return c
+}
+
+// Config returns the configuration of the LRC encoder.
+func (l *LRC) Config() Config {
+	return Config{
+		DataShards:   l.dataShards,
+		ParityShards: l.globalShards,
+		LocalShards:  l.localShards,
+		Matrix:       MatrixVandermonde,
+		Fingerprint: fingerprint(l.dataShards, l.globalShards, l.localShards,
+			l.global.(*reedSolomon).m, l.localLeft.(*reedSolomon).m, l.localRight.(*reedSolomon).m),
+	}
+}
+
+// fingerprint returns a 64 bit FNV-1a hash of the shard layout and matrices.
+func fingerprint(dataShards, parityShards, localShards int, matrices ...matrix) uint64 {
+	h := fnv.New64a()
+	var tmp [8]byte
+	binary.BigEndian.PutUint16(tmp[0:], uint16(dataShards))
+	binary.BigEndian.PutUint16(tmp[2:], uint16(parityShards))
+	binary.BigEndian.PutUint16(tmp[4:], uint16(localShards))
+	binary.BigEndian.PutUint16(tmp[6:], uint16(len(matrices)))
+	h.Write(tmp[:])
+	for _, m := range matrices {
+		binary.BigEndian.PutUint16(tmp[0:], uint16(len(m)))
+		h.Write(tmp[:2])
+		for _, row := range m {
+			h.Write(row)
+		}
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,148 @@
+package reedsolomon
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	// Rows of a larger Cauchy matrix, so it differs from WithCauchyMatrix.
+	custom, err := buildMatrixCauchy(5, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		data, parity int
+		opts         []Option
+		matrix       MatrixType
+	}{
+		{5, 3, nil, MatrixVandermonde},
+		{5, 3, []Option{WithCauchyMatrix()}, MatrixCauchy},
+		{5, 3, []Option{WithPAR1Matrix()}, MatrixPAR1},
+		{5, 1, []Option{WithFastOneParityMatrix()}, MatrixXor},
+		{5, 3, []Option{WithFastOneParityMatrix()}, MatrixVandermonde},
+		{5, 3, []Option{WithCustomMatrix(custom[6:])}, MatrixCustom},
+		{5, 0, nil, MatrixVandermonde},
+	}
+	fingerprints := make(map[uint64]bool)
+	for _, test := range tests {
+		enc, err := New(test.data, test.parity, test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := enc.(ConfigEncoder).Config()
+		if cfg.DataShards != test.data || cfg.ParityShards != test.parity || cfg.Matrix != test.matrix {
+			t.Fatalf("unexpected config %+v", cfg)
+		}
+		if test.parity == 3 && test.opts != nil {
+			if fingerprints[cfg.Fingerprint] {
+				t.Errorf("%v: duplicate fingerprint", test.matrix)
+			}
+			fingerprints[cfg.Fingerprint] = true
+		}
+
+		js, err := json.Marshal(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromJSON Config
+		if err := json.Unmarshal(js, &fromJSON); err != nil {
+			t.Fatal(err)
+		}
+		bin, err := cfg.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromBin Config
+		if err := fromBin.UnmarshalBinary(bin); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, c := range []Config{fromJSON, fromBin} {
+			// Matrix options must be ignored.
+			enc2, err := NewFromConfig(c, WithPAR1Matrix(), WithMaxGoroutines(1))
+			if err != nil {
+				t.Fatalf("%s: %v", js, err)
+			}
+			if enc2.(ConfigEncoder).Config().Fingerprint != cfg.Fingerprint {
+				t.Fatalf("%s: fingerprint mismatch", js)
+			}
+			if test.parity == 0 {
+				continue
+			}
+			a, b := randomBytes(test.data+test.parity, 100), make([][]byte, test.data+test.parity)
+			for i := range b {
+				b[i] = append([]byte(nil), a[i]...)
+			}
+			if err := enc.Encode(a); err != nil {
+				t.Fatal(err)
+			}
+			if err := enc2.Encode(b); err != nil {
+				t.Fatal(err)
+			}
+			for i := range a {
+				if !bytes.Equal(a[i], b[i]) {
+					t.Fatalf("%s: shard %d mismatch", js, i)
+				}
+			}
+		}
+
+		cfg.Fingerprint++
+		if _, err := NewFromConfig(cfg); err != ErrConfigMismatch {
+			t.Errorf("expected %v, got %v", ErrConfigMismatch, err)
+		}
+	}
+}
+
+func TestConfigLRC(t *testing.T) {
+	lrc, err := NewLRC(4, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := lrc.(ConfigEncoder).Config()
+	if cfg.DataShards != 4 || cfg.LocalShards != 2 || cfg.ParityShards != 3 {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+	bin, err := cfg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec Config
+	if err := dec.UnmarshalBinary(bin); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Fingerprint != cfg.Fingerprint {
+		t.Fatal("fingerprint was not preserved")
+	}
+	if _, err := NewLRCFromConfig(dec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFromConfig(dec); err != ErrInvalidConfig {
+		t.Errorf("expected %v, got %v", ErrInvalidConfig, err)
+	}
+	enc, _ := New(4, 3)
+	if _, err := NewLRCFromConfig(enc.(ConfigEncoder).Config()); err != ErrInvalidConfig {
+		t.Errorf("expected %v, got %v", ErrInvalidConfig, err)
+	}
+}
+
+func TestConfigUnmarshalInvalid(t *testing.T) {
+	enc, err := New(3, 2, WithCustomMatrix([][]byte{{1, 2, 3}, {4, 5, 6}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := enc.(ConfigEncoder).Config().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Config
+	for _, b := range [][]byte{nil, bin[:5], bin[:len(bin)-1], append(bin, 0), append([]byte{2}, bin[1:]...)} {
+		if err := c.UnmarshalBinary(b); err != ErrInvalidConfig {
+			t.Errorf("%v: expected %v, got %v", b, ErrInvalidConfig, err)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"matrix":"unknown"}`), &c); err == nil {
+		t.Error("expected error for unknown matrix type")
+	}
+}
@@ -0,0 +1,95 @@
+package reedsolomon
+
+// DataDelta returns the difference between the old and new content of a data shard.
+// The difference can be sent to the holders of the parity shards
+// and applied with DeltaCoder.ApplyDelta.
+// oldData and newData must be the same size and are not modified.
+func DataDelta(oldData, newData []byte) ([]byte, error) {
+	if len(oldData) != len(newData) {
+		return nil, ErrShardSize
+	}
+	if len(oldData) == 0 {
+		return nil, ErrShardNoData
+	}
+	delta := make([]byte, len(oldData))
+	copy(delta, oldData)
+	sliceXor(newData, delta, &defaultOptions)
+	return delta, nil
+}
+
+// DeltaCoder applies changes of data shards to parity shards.
+//
+// It only holds the parity coefficients of the encoding matrix,
+// so it can be used where only parity shards are stored,
+// without creating an Encoder for the full set of shards.
+type DeltaCoder struct {
+	dataShards   int
+	parityShards int
+	parity       [][]byte
+	o            options
+}
+
+// NewDeltaCoder creates a DeltaCoder for the encoder with the given configuration.
+// The configuration can be obtained from Encoder.Config.
+// Options can be supplied to control performance, but the matrix is always taken from the configuration.
+// If the fingerprint of the matrix doesn't match the configuration
+// ErrConfigMismatch is returned.
+func NewDeltaCoder(c Config, opts ...Option) (*DeltaCoder, error) {
+	if c.LocalShards != 0 || int(c.Matrix) >= len(matrixTypeNames) ||
+		(c.Matrix == MatrixCustom) != (c.CustomMatrix != nil) {
+		return nil, ErrInvalidConfig
+	}
+	if c.DataShards <= 0 || c.ParityShards <= 0 {
+		return nil, ErrInvShardNum
+	}
+	if c.DataShards+c.ParityShards > 256 {
+		return nil, ErrMaxShardNum
+	}
+	d := DeltaCoder{
+		dataShards:   c.DataShards,
+		parityShards: c.ParityShards,
+		o:            defaultOptions,
+	}
+	for _, opt := range append(opts[:len(opts):len(opts)], withConfigMatrix(c)) {
+		opt(&d.o)
+	}
+	m, _, err := buildEncodingMatrix(c.DataShards, c.ParityShards, &d.o)
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint(c.DataShards, c.ParityShards, 0, m) != c.Fingerprint {
+		return nil, ErrConfigMismatch
+	}
+	d.parity = m[c.DataShards:]
+	return &d, nil
+}
+
+// Coefficient returns the coefficient data shard dataIdx is multiplied with
+// when it is added to parity shard parityIdx.
+// parityIdx is the index among the parity shards, starting at 0.
+func (d *DeltaCoder) Coefficient(parityIdx, dataIdx int) (byte, error) {
+	if parityIdx < 0 || parityIdx >= d.parityShards || dataIdx < 0 || dataIdx >= d.dataShards {
+		return 0, ErrInvShardNum
+	}
+	return d.parity[parityIdx][dataIdx], nil
+}
+
+// ApplyDelta updates parity shard parityIdx with the change of data shard dataIdx.
+// The delta must be created with DataDelta, and parity is updated in place.
+// parityIdx is the index among the parity shards, starting at 0.
+//
+// Deltas of different data shards can be applied in any order.
+func (d *DeltaCoder) ApplyDelta(parityIdx, dataIdx int, delta, parity []byte) error {
+	c, err := d.Coefficient(parityIdx, dataIdx)
+	if err != nil {
+		return err
+	}
+	if len(delta) != len(parity) {
+		return ErrShardSize
+	}
+	if len(delta) == 0 {
+		return ErrShardNoData
+	}
+	galMulSliceXor(c, delta, parity, &d.o)
+	return nil
+}
@@ -0,0 +1,79 @@
+package reedsolomon
+
+import (
+	"testing"
+)
+
+func TestDeltaCoder(t *testing.T) {
+	const dataShards, parityShards, size = 6, 3, 5000
+	for _, opts := range [][]Option{nil, {WithCauchyMatrix()}, {WithPAR1Matrix()}} {
+		enc, err := New(dataShards, parityShards, testOptions(opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards := make([][]byte, dataShards+parityShards)
+		for i := range shards {
+			shards[i] = make([]byte, size)
+			fillRandom(shards[i])
+		}
+		if err := enc.Encode(shards); err != nil {
+			t.Fatal(err)
+		}
+
+		// The parity holders only have the configuration.
+		dc, err := NewDeltaCoder(enc.(ConfigEncoder).Config(), testOptions()...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, idx := range []int{0, 4} {
+			newData := make([]byte, size)
+			fillRandom(newData)
+			delta, err := DataDelta(shards[idx], newData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for p := 0; p < parityShards; p++ {
+				if err := dc.ApplyDelta(p, idx, delta, shards[dataShards+p]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			shards[idx] = newData
+		}
+		ok, err := enc.Verify(shards)
+		if err != nil || !ok {
+			t.Fatal("verification failed", err)
+		}
+		c, err := dc.Coefficient(1, 2)
+		if err != nil || c != enc.(*reedSolomon).parity[1][2] {
+			t.Errorf("coefficient mismatch: %d, %v", c, err)
+		}
+	}
+
+	enc, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc, err := NewDeltaCoder(enc.(ConfigEncoder).Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.ApplyDelta(parityShards, 0, make([]byte, 10), make([]byte, 10)); err != ErrInvShardNum {
+		t.Errorf("expected %v, got %v", ErrInvShardNum, err)
+	}
+	if err := dc.ApplyDelta(0, 0, make([]byte, 10), make([]byte, 11)); err != ErrShardSize {
+		t.Errorf("expected %v, got %v", ErrShardSize, err)
+	}
+	if _, err := DataDelta(make([]byte, 10), make([]byte, 11)); err != ErrShardSize {
+		t.Errorf("expected %v, got %v", ErrShardSize, err)
+	}
+	cfg := enc.(ConfigEncoder).Config()
+	cfg.Fingerprint++
+	if _, err := NewDeltaCoder(cfg); err != ErrConfigMismatch {
+		t.Errorf("expected %v, got %v", ErrConfigMismatch, err)
+	}
+	cfg = enc.(ConfigEncoder).Config()
+	cfg.LocalShards = 2
+	if _, err := NewDeltaCoder(cfg); err != ErrInvalidConfig {
+		t.Errorf("expected %v, got %v", ErrInvalidConfig, err)
+	}
+}
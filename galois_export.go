@@ -0,0 +1,17 @@
+package reedsolomon
+
+import "github.com/klauspost/reedsolomon/internal/galois"
+
+// Replace the portable slice operations used by the gf256 package
+// with the accelerated ones. The default options reflect the detected CPU features.
+func init() {
+	galois.MulSlice = func(c byte, in, out []byte) {
+		galMulSlice(c, in, out, &defaultOptions)
+	}
+	galois.MulSliceXor = func(c byte, in, out []byte) {
+		galMulSliceXor(c, in, out, &defaultOptions)
+	}
+	galois.SliceXor = func(in, out []byte) {
+		sliceXor(in, out, &defaultOptions)
+	}
+}
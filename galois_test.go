@@ -10,6 +10,8 @@ package reedsolomon
 import (
 	"bytes"
 	"testing"
+
+	"github.com/klauspost/reedsolomon/internal/galois"
 )
 
 func TestAssociativity(t *testing.T) {
@@ -178,6 +180,23 @@ func TestGalois(t *testing.T) {
 	}
 }
 
+// TestSharedTables checks that the tables generated by the galois
+// package are the same as the ones used by the encoders.
+func TestSharedTables(t *testing.T) {
+	if *galois.MulTable != mulTable {
+		t.Error("mulTable mismatch")
+	}
+	if *galois.LogTable != logTable {
+		t.Error("logTable mismatch")
+	}
+	if !bytes.Equal(galois.ExpTable, expTable) {
+		t.Error("expTable mismatch")
+	}
+	if *galois.InvTable != invTable {
+		t.Error("invTable mismatch")
+	}
+}
+
 func TestSliceGalAdd(t *testing.T) {
 
 	lengthList := []int{16, 32, 34}
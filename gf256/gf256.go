@@ -0,0 +1,85 @@
+// Package gf256 provides arithmetic over GF(2^8), the 8-bit Galois field
+// used by the reedsolomon package, and matrices over the field.
+//
+// The field is generated by the polynomial x^8 + x^4 + x^3 + x^2 + 1 (29),
+// so results are compatible with the encoders in reedsolomon.
+//
+// When the reedsolomon package is also linked, slice operations use the same
+// SIMD accelerated code as the encoders when supported by the CPU.
+// Otherwise portable implementations are used.
+package gf256
+
+import "github.com/klauspost/reedsolomon/internal/galois"
+
+// Add returns a + b. Addition and subtraction are both exclusive or.
+func Add(a, b byte) byte {
+	return a ^ b
+}
+
+// Sub returns a - b, which is the same as a + b.
+func Sub(a, b byte) byte {
+	return a ^ b
+}
+
+// Mul returns a * b.
+func Mul(a, b byte) byte {
+	return galois.MulTable[a][b]
+}
+
+// Div returns a / b.
+// Div panics if b is 0.
+func Div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	if b == 0 {
+		panic("gf256: division by zero")
+	}
+	logResult := int(galois.LogTable[a]) - int(galois.LogTable[b])
+	if logResult < 0 {
+		logResult += 255
+	}
+	return galois.ExpTable[logResult]
+}
+
+// Inv returns the multiplicative inverse of a.
+// Inv panics if a is 0.
+func Inv(a byte) byte {
+	if a == 0 {
+		panic("gf256: inverse of zero")
+	}
+	return galois.InvTable[a]
+}
+
+// Exp returns a raised to the power n.
+// The result will be the same as multiplying a times itself n times.
+// n must not be negative.
+func Exp(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	logResult := int(galois.LogTable[a]) * n
+	logResult %= 255
+	return galois.ExpTable[logResult]
+}
+
+// MulSlice sets out[i] = c * in[i] for all elements of in.
+// out must be at least as long as in.
+func MulSlice(c byte, in, out []byte) {
+	galois.MulSlice(c, in, out[:len(in)])
+}
+
+// MulSliceXor sets out[i] ^= c * in[i] for all elements of in.
+// out must be at least as long as in.
+func MulSliceXor(c byte, in, out []byte) {
+	galois.MulSliceXor(c, in, out[:len(in)])
+}
+
+// SliceXor sets out[i] ^= in[i] for all elements of in.
+// out must be at least as long as in.
+func SliceXor(in, out []byte) {
+	galois.SliceXor(in, out[:len(in)])
+}
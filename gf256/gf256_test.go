@@ -0,0 +1,95 @@
+package gf256
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// slowMul multiplies without tables.
+func slowMul(a, b byte) byte {
+	var p byte
+	for b != 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 29
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func TestMul(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 0; b < 256; b++ {
+			got, want := Mul(byte(a), byte(b)), slowMul(byte(a), byte(b))
+			if got != want {
+				t.Fatalf("Mul(%d, %d): got %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestDivInv(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			q := Div(byte(a), byte(b))
+			if Mul(q, byte(b)) != byte(a) {
+				t.Fatalf("Div(%d, %d) = %d is not an inverse of Mul", a, b, q)
+			}
+		}
+		if a > 0 && Mul(byte(a), Inv(byte(a))) != 1 {
+			t.Fatalf("Inv(%d) = %d is not an inverse", a, Inv(byte(a)))
+		}
+	}
+}
+
+func TestExp(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		want := byte(1)
+		for n := 0; n < 600; n++ {
+			if got := Exp(byte(a), n); got != want {
+				t.Fatalf("Exp(%d, %d): got %d, want %d", a, n, got, want)
+			}
+			want = Mul(want, byte(a))
+		}
+	}
+}
+
+func TestSliceOps(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for _, size := range []int{0, 1, 15, 16, 31, 32, 33, 63, 64, 127, 128, 129, 1000, 10001} {
+		in := make([]byte, size)
+		rng.Read(in)
+		base := make([]byte, size)
+		rng.Read(base)
+		for _, c := range []byte{0, 1, 2, 29, 142, 255} {
+			out := make([]byte, size+10)
+			MulSlice(c, in, out)
+			outXor := append([]byte(nil), base...)
+			MulSliceXor(c, in, outXor)
+			for i := range in {
+				if out[i] != Mul(c, in[i]) {
+					t.Fatalf("MulSlice(%d) size %d: mismatch at %d", c, size, i)
+				}
+				if outXor[i] != base[i]^Mul(c, in[i]) {
+					t.Fatalf("MulSliceXor(%d) size %d: mismatch at %d", c, size, i)
+				}
+			}
+			if !bytes.Equal(out[size:], make([]byte, 10)) {
+				t.Fatalf("MulSlice(%d) size %d: wrote past input length", c, size)
+			}
+		}
+		out := append([]byte(nil), base...)
+		SliceXor(in, out)
+		for i := range in {
+			if out[i] != base[i]^in[i] {
+				t.Fatalf("SliceXor size %d: mismatch at %d", size, i)
+			}
+		}
+	}
+}
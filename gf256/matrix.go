@@ -0,0 +1,314 @@
+package gf256
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Matrix is a matrix over GF(2^8), indexed as [row][col].
+type Matrix [][]byte
+
+// ErrInvalidRowSize will be returned if attempting to create a matrix with negative or zero row number.
+var ErrInvalidRowSize = errors.New("invalid row size")
+
+// ErrInvalidColSize will be returned if attempting to create a matrix with negative or zero column number.
+var ErrInvalidColSize = errors.New("invalid column size")
+
+// ErrColSizeMismatch is returned if the size of matrix columns mismatch.
+var ErrColSizeMismatch = errors.New("column size is not the same for all rows")
+
+// ErrMatrixSize is returned if matrix dimensions do not match.
+var ErrMatrixSize = errors.New("matrix sizes do not match")
+
+// ErrSingular is returned if the matrix is singular and cannot be inverted.
+var ErrSingular = errors.New("matrix is singular")
+
+// ErrNotSquare is returned if attempting to invert a non-square matrix.
+var ErrNotSquare = errors.New("only square matrices can be inverted")
+
+// NewMatrix returns a matrix of zeros.
+func NewMatrix(rows, cols int) (Matrix, error) {
+	if rows <= 0 {
+		return nil, ErrInvalidRowSize
+	}
+	if cols <= 0 {
+		return nil, ErrInvalidColSize
+	}
+
+	m := Matrix(make([][]byte, rows))
+	for i := range m {
+		m[i] = make([]byte, cols)
+	}
+	return m, nil
+}
+
+// NewMatrixData initializes a matrix with the given row-major data.
+// Note that data is not copied from input.
+func NewMatrixData(data [][]byte) (Matrix, error) {
+	m := Matrix(data)
+	err := m.Check()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Identity returns an identity matrix of the given size.
+func Identity(size int) (Matrix, error) {
+	m, err := NewMatrix(size, size)
+	if err != nil {
+		return nil, err
+	}
+	for i := range m {
+		m[i][i] = 1
+	}
+	return m, nil
+}
+
+// Vandermonde returns a Vandermonde matrix where row r is [1, r, r^2, ...].
+// Any subset of up to 256 rows that forms a square matrix is invertible.
+func Vandermonde(rows, cols int) (Matrix, error) {
+	m, err := NewMatrix(rows, cols)
+	if err != nil {
+		return nil, err
+	}
+	for r, row := range m {
+		for c := range row {
+			row[c] = Exp(byte(r), c)
+		}
+	}
+	return m, nil
+}
+
+// Check returns an error if the matrix is empty or its rows differ in length.
+func (m Matrix) Check() error {
+	rows := len(m)
+	if rows <= 0 {
+		return ErrInvalidRowSize
+	}
+	cols := len(m[0])
+	if cols <= 0 {
+		return ErrInvalidColSize
+	}
+
+	for _, col := range m {
+		if len(col) != cols {
+			return ErrColSizeMismatch
+		}
+	}
+	return nil
+}
+
+// Rows returns the number of rows.
+func (m Matrix) Rows() int {
+	return len(m)
+}
+
+// Cols returns the number of columns.
+func (m Matrix) Cols() int {
+	if len(m) == 0 {
+		return 0
+	}
+	return len(m[0])
+}
+
+// String returns a human-readable string of the matrix contents.
+//
+// Example: [[1, 2], [3, 4]]
+func (m Matrix) String() string {
+	rowOut := make([]string, 0, len(m))
+	for _, row := range m {
+		colOut := make([]string, 0, len(row))
+		for _, col := range row {
+			colOut = append(colOut, strconv.Itoa(int(col)))
+		}
+		rowOut = append(rowOut, "["+strings.Join(colOut, ", ")+"]")
+	}
+	return "[" + strings.Join(rowOut, ", ") + "]"
+}
+
+// Clone returns a copy of the matrix.
+func (m Matrix) Clone() Matrix {
+	result := make(Matrix, len(m))
+	for i, row := range m {
+		result[i] = append([]byte(nil), row...)
+	}
+	return result
+}
+
+// Multiply multiplies this matrix (the one on the left) by another
+// matrix (the one on the right) and returns a new matrix with the result.
+func (m Matrix) Multiply(right Matrix) (Matrix, error) {
+	if m.Cols() != len(right) {
+		return nil, fmt.Errorf("columns on left (%d) is different than rows on right (%d)", m.Cols(), len(right))
+	}
+	result, err := NewMatrix(len(m), right.Cols())
+	if err != nil {
+		return nil, err
+	}
+	for r, row := range result {
+		for c := range row {
+			var value byte
+			for i := range m[r] {
+				value ^= Mul(m[r][i], right[i][c])
+			}
+			row[c] = value
+		}
+	}
+	return result, nil
+}
+
+// MultiplyVector returns m * v, where v is a column vector
+// with one element per column of m.
+func (m Matrix) MultiplyVector(v []byte) ([]byte, error) {
+	if m.Cols() != len(v) {
+		return nil, ErrMatrixSize
+	}
+	result := make([]byte, len(m))
+	for r, row := range m {
+		var value byte
+		for c, x := range row {
+			value ^= Mul(x, v[c])
+		}
+		result[r] = value
+	}
+	return result, nil
+}
+
+// Augment returns the concatenation of this matrix and the matrix on the right.
+func (m Matrix) Augment(right Matrix) (Matrix, error) {
+	if len(m) != len(right) {
+		return nil, ErrMatrixSize
+	}
+
+	result, err := NewMatrix(len(m), m.Cols()+right.Cols())
+	if err != nil {
+		return nil, err
+	}
+	for r, row := range result {
+		n := copy(row, m[r])
+		copy(row[n:], right[r])
+	}
+	return result, nil
+}
+
+// SubMatrix returns the part of this matrix from rows rmin to rmax
+// and columns cmin to cmax, excluding rmax and cmax. Data is copied.
+func (m Matrix) SubMatrix(rmin, cmin, rmax, cmax int) (Matrix, error) {
+	if rmin < 0 || rmax > len(m) {
+		return nil, ErrInvalidRowSize
+	}
+	if cmin < 0 || cmax > m.Cols() {
+		return nil, ErrInvalidColSize
+	}
+	result, err := NewMatrix(rmax-rmin, cmax-cmin)
+	if err != nil {
+		return nil, err
+	}
+	for r := range result {
+		copy(result[r], m[rmin+r][cmin:cmax])
+	}
+	return result, nil
+}
+
+// SelectRows returns a matrix made from the given rows of m, in order.
+// Data is copied.
+func (m Matrix) SelectRows(rows []int) (Matrix, error) {
+	result := make(Matrix, len(rows))
+	for i, r := range rows {
+		if r < 0 || r >= len(m) {
+			return nil, ErrInvalidRowSize
+		}
+		result[i] = append([]byte(nil), m[r]...)
+	}
+	return result, nil
+}
+
+// SwapRows exchanges two rows in the matrix.
+func (m Matrix) SwapRows(r1, r2 int) error {
+	if r1 < 0 || len(m) <= r1 || r2 < 0 || len(m) <= r2 {
+		return ErrInvalidRowSize
+	}
+	m[r2], m[r1] = m[r1], m[r2]
+	return nil
+}
+
+// IsSquare returns true if the matrix is square.
+func (m Matrix) IsSquare() bool {
+	return len(m) == m.Cols()
+}
+
+// Invert returns the inverse of this matrix.
+// Returns ErrSingular when the matrix is singular and doesn't have an inverse.
+// The matrix must be square, otherwise ErrNotSquare is returned.
+func (m Matrix) Invert() (Matrix, error) {
+	if !m.IsSquare() {
+		return nil, ErrNotSquare
+	}
+
+	size := len(m)
+	work, err := Identity(size)
+	if err != nil {
+		return nil, err
+	}
+	work, err = m.Augment(work)
+	if err != nil {
+		return nil, err
+	}
+
+	if work.reduce(size) != size {
+		return nil, ErrSingular
+	}
+
+	return work.SubMatrix(0, size, size, size*2)
+}
+
+// Rank returns the number of linearly independent rows of the matrix.
+func (m Matrix) Rank() int {
+	if len(m) == 0 {
+		return 0
+	}
+	return m.Clone().reduce(m.Cols())
+}
+
+// reduce converts the first cols columns of m to reduced row echelon form
+// in place, applying the same row operations to the remaining columns.
+// The number of pivots found is returned.
+func (m Matrix) reduce(cols int) int {
+	rank := 0
+	for c := 0; c < cols && rank < len(m); c++ {
+		// Find a row with a non-zero element in this column.
+		pivot := -1
+		for r := rank; r < len(m); r++ {
+			if m[r][c] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot < 0 {
+			continue
+		}
+		m[rank], m[pivot] = m[pivot], m[rank]
+		row := m[rank]
+
+		// Scale to 1.
+		if row[c] != 1 {
+			scale := Inv(row[c])
+			for i := range row {
+				row[i] = Mul(row[i], scale)
+			}
+		}
+
+		// Clear the column in all other rows by subtracting
+		// a multiple of the pivot row.
+		for r := range m {
+			if r != rank && m[r][c] != 0 {
+				MulSliceXor(m[r][c], row, m[r])
+			}
+		}
+		rank++
+	}
+	return rank
+}
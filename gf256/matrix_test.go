@@ -0,0 +1,99 @@
+package gf256
+
+import (
+	"testing"
+)
+
+func TestMatrixInvert(t *testing.T) {
+	m, err := NewMatrixData([][]byte{
+		{56, 23, 98},
+		{3, 100, 200},
+		{45, 201, 123},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := m.Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "[[175, 133, 33], [130, 13, 245], [112, 35, 126]]"
+	if inv.String() != expect {
+		t.Fatal(inv.String(), "!=", expect)
+	}
+	id, err := m.Multiply(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := Identity(3)
+	if id.String() != want.String() {
+		t.Fatal(id.String(), "!=", want.String())
+	}
+
+	singular, _ := NewMatrixData([][]byte{
+		{4, 2},
+		{12, 6},
+	})
+	if _, err := singular.Invert(); err != ErrSingular {
+		t.Fatalf("expected %v, got %v", ErrSingular, err)
+	}
+	notSquare, _ := NewMatrix(2, 3)
+	if _, err := notSquare.Invert(); err != ErrNotSquare {
+		t.Fatalf("expected %v, got %v", ErrNotSquare, err)
+	}
+}
+
+func TestMatrixRank(t *testing.T) {
+	tests := []struct {
+		m    Matrix
+		rank int
+	}{
+		{Matrix{{1, 0}, {0, 1}}, 2},
+		{Matrix{{4, 2}, {12, 6}}, 1},
+		{Matrix{{0, 0, 0}, {0, 0, 0}}, 0},
+		{Matrix{{1, 2, 3}, {2, 4, 6}, {0, 0, 1}}, 2},
+		{Matrix{{1, 2, 3, 4}}, 1},
+		{Matrix{{1}, {2}, {3}}, 1},
+	}
+	for _, test := range tests {
+		before := test.m.String()
+		if got := test.m.Rank(); got != test.rank {
+			t.Errorf("%v: got rank %d, want %d", test.m, got, test.rank)
+		}
+		if test.m.String() != before {
+			t.Errorf("%v: Rank modified the matrix", before)
+		}
+	}
+
+	// Any square selection of rows from a Vandermonde matrix is invertible.
+	vm, err := Vandermonde(20, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Rank() != 5 {
+		t.Fatalf("Vandermonde rank %d != 5", vm.Rank())
+	}
+	sub, err := vm.SelectRows([]int{2, 5, 7, 11, 19})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Invert(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatrixMultiplyVector(t *testing.T) {
+	m := Matrix{{1, 2}, {3, 4}}
+	v := []byte{5, 6}
+	got, err := m.MultiplyVector(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	col, _ := m.Multiply(Matrix{{5}, {6}})
+	if got[0] != col[0][0] || got[1] != col[1][0] {
+		t.Fatalf("got %v, want %v", got, col)
+	}
+	if _, err := m.MultiplyVector([]byte{1}); err != ErrMatrixSize {
+		t.Fatalf("expected %v, got %v", ErrMatrixSize, err)
+	}
+}
@@ -0,0 +1,86 @@
+// Package galois holds the Galois field tables and slice operations
+// shared by the reedsolomon package and its public subpackages.
+//
+// The tables and portable slice operations are set up when the package
+// is initialized. When reedsolomon is linked, it replaces the slice
+// operations with its SIMD accelerated versions, without exporting
+// them from reedsolomon itself.
+package galois
+
+// generatingPolynomial is x^8 + x^4 + x^3 + x^2 + 1,
+// the polynomial used by the reedsolomon package.
+const generatingPolynomial = 29
+
+var (
+	// MulTable contains the product of all pairs of field elements.
+	MulTable *[256][256]byte
+
+	// LogTable contains the logarithm of each non-zero field element.
+	LogTable *[256]byte
+
+	// ExpTable contains the inverse of LogTable, repeated twice.
+	ExpTable []byte
+
+	// InvTable contains the multiplicative inverse of each non-zero field element.
+	InvTable *[256]byte
+
+	// MulSlice sets out[i] = c * in[i].
+	MulSlice func(c byte, in, out []byte) = mulSlice
+
+	// MulSliceXor sets out[i] ^= c * in[i].
+	MulSliceXor func(c byte, in, out []byte) = mulSliceXor
+
+	// SliceXor sets out[i] ^= in[i].
+	SliceXor func(in, out []byte) = sliceXor
+)
+
+func init() {
+	var logTable, invTable [256]byte
+	expTable := make([]byte, 255*2)
+	b := 1
+	for log := 0; log < 255; log++ {
+		expTable[log] = byte(b)
+		expTable[log+255] = byte(b)
+		logTable[b] = byte(log)
+		b <<= 1
+		if b >= 256 {
+			b = (b - 256) ^ generatingPolynomial
+		}
+	}
+
+	var mulTable [256][256]byte
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			mulTable[a][b] = expTable[int(logTable[a])+int(logTable[b])]
+		}
+		invTable[a] = expTable[255-int(logTable[a])]
+	}
+
+	MulTable = &mulTable
+	LogTable = &logTable
+	ExpTable = expTable
+	InvTable = &invTable
+}
+
+func mulSlice(c byte, in, out []byte) {
+	mt := MulTable[c][:256]
+	out = out[:len(in)]
+	for i, v := range in {
+		out[i] = mt[v]
+	}
+}
+
+func mulSliceXor(c byte, in, out []byte) {
+	mt := MulTable[c][:256]
+	out = out[:len(in)]
+	for i, v := range in {
+		out[i] ^= mt[v]
+	}
+}
+
+func sliceXor(in, out []byte) {
+	out = out[:len(in)]
+	for i, v := range in {
+		out[i] ^= v
+	}
+}
@@ -0,0 +1,260 @@
+package reedsolomon
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"sync"
+)
+
+// ErrInversionCacheDisabled is returned when importing or warming
+// the inversion cache of an encoder created with WithInversionCache(false).
+var ErrInversionCacheDisabled = errors.New("inversion cache is disabled")
+
+// ErrInvalidInversionCache is returned if an exported inversion cache
+// is corrupted or cannot be decoded.
+var ErrInvalidInversionCache = errors.New("invalid inversion cache data")
+
+const (
+	// inversionCacheVersion is the first byte of an exported inversion cache.
+	inversionCacheVersion = 1
+
+	// inversionCacheHeaderSize is version, fingerprint, data shards, total shards and entry count.
+	inversionCacheHeaderSize = 1 + 8 + 2 + 2 + 4
+)
+
+// cached returns the keys and matrices of all cached matrices.
+func (t *inversionTree) cached() (keys [][]int, matrices []matrix) {
+	if t == nil {
+		return nil, nil
+	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	keys = make([][]int, len(t.entries))
+	matrices = make([]matrix, len(t.entries))
+	for i, e := range t.entries {
+		keys[i] = e.key
+		matrices[i] = e.matrix
+	}
+	return keys, matrices
+}
+
+// ExportInversionCache returns the matrices of the inversion cache
+// in a compact binary format that can be loaded with ImportInversionCache.
+//
+// The output contains the fingerprint of the encoding matrix,
+// so it can only be imported to an encoder with an identical configuration.
+func (r *reedSolomon) ExportInversionCache() ([]byte, error) {
+	if r.tree == nil {
+		return nil, ErrInversionCacheDisabled
+	}
+	keys, matrices := r.tree.cached()
+	size := inversionCacheHeaderSize + 4
+	for _, key := range keys {
+		size += 1 + len(key) + r.DataShards*r.DataShards
+	}
+	dst := make([]byte, inversionCacheHeaderSize, size)
+	dst[0] = inversionCacheVersion
+	binary.BigEndian.PutUint64(dst[1:], r.Config().Fingerprint)
+	binary.BigEndian.PutUint16(dst[9:], uint16(r.DataShards))
+	binary.BigEndian.PutUint16(dst[11:], uint16(r.Shards))
+	binary.BigEndian.PutUint32(dst[13:], uint32(len(keys)))
+	for i, key := range keys {
+		dst = append(dst, byte(len(key)))
+		for _, idx := range key {
+			dst = append(dst, byte(idx))
+		}
+		for _, row := range matrices[i] {
+			dst = append(dst, row...)
+		}
+	}
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.Checksum(dst, crc32cTable))
+	return append(dst, crc[:]...), nil
+}
+
+// ImportInversionCache adds matrices exported with ExportInversionCache
+// to the inversion cache.
+//
+// Every matrix is checked against the encoding matrix before it is added.
+// If the data was exported from an encoder with a different configuration,
+// ErrConfigMismatch is returned. If the data is corrupted,
+// ErrInvalidInversionCache is returned and the cache is unchanged.
+// If the cache is limited, the imported matrices may evict existing ones.
+func (r *reedSolomon) ImportInversionCache(data []byte) error {
+	if r.tree == nil {
+		return ErrInversionCacheDisabled
+	}
+	if len(data) < inversionCacheHeaderSize+4 || data[0] != inversionCacheVersion {
+		return ErrInvalidInversionCache
+	}
+	crc := binary.BigEndian.Uint32(data[len(data)-4:])
+	data = data[:len(data)-4]
+	if crc32.Checksum(data, crc32cTable) != crc {
+		return ErrInvalidInversionCache
+	}
+	if binary.BigEndian.Uint64(data[1:]) != r.Config().Fingerprint ||
+		int(binary.BigEndian.Uint16(data[9:])) != r.DataShards ||
+		int(binary.BigEndian.Uint16(data[11:])) != r.Shards {
+		return ErrConfigMismatch
+	}
+	n := int(binary.BigEndian.Uint32(data[13:]))
+	data = data[inversionCacheHeaderSize:]
+
+	// Decode everything before inserting anything.
+	matrixSize := r.DataShards * r.DataShards
+	keys := make([][]int, 0, n)
+	matrices := make([]matrix, 0, n)
+	for i := 0; i < n; i++ {
+		if len(data) < 1 {
+			return ErrInvalidInversionCache
+		}
+		keyLen := int(data[0])
+		data = data[1:]
+		if keyLen == 0 || keyLen > r.ParityShards || len(data) < keyLen+matrixSize {
+			return ErrInvalidInversionCache
+		}
+		// Reconstruction only caches keys of missing rows before the
+		// last of the DataShards+keyLen first rows, which must be present.
+		key := make([]int, keyLen)
+		for j := range key {
+			key[j] = int(data[j])
+			if key[j] >= r.DataShards+keyLen-1 || (j > 0 && key[j] <= key[j-1]) {
+				return ErrInvalidInversionCache
+			}
+		}
+		data = data[keyLen:]
+		m, _ := newMatrix(r.DataShards, r.DataShards)
+		for _, row := range m {
+			copy(row, data[:r.DataShards])
+			data = data[r.DataShards:]
+		}
+		if !r.isDecodeMatrix(key, m) {
+			return ErrInvalidInversionCache
+		}
+		keys = append(keys, key)
+		matrices = append(matrices, m)
+	}
+	if len(data) != 0 {
+		return ErrInvalidInversionCache
+	}
+	for i, key := range keys {
+		err := r.tree.InsertInvertedMatrix(key, matrices[i], r.Shards)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isDecodeMatrix returns whether m is the inverse of the encoding matrix rows
+// used for reconstruction when the rows in key are missing.
+func (r *reedSolomon) isDecodeMatrix(key []int, m matrix) bool {
+	subMatrix, _ := newMatrix(r.DataShards, r.DataShards)
+	for row, subMatrixRow := 0, 0; subMatrixRow < r.DataShards; row++ {
+		if len(key) > 0 && key[0] == row {
+			key = key[1:]
+			continue
+		}
+		copy(subMatrix[subMatrixRow], r.m[row])
+		subMatrixRow++
+	}
+	product, err := m.Multiply(subMatrix)
+	if err != nil {
+		return false
+	}
+	identity, _ := identityMatrix(r.DataShards)
+	return product.equal(identity)
+}
+
+// WarmInversionCache calculates and caches the decoding matrices for
+// every combination of up to maxErasures missing shards.
+//
+// For k data shards, the number of matrices for e erasures is
+// (k+e-1)! / (e! * (k-1)!), so this can take a long time for wide
+// configurations. If the cache is limited, only the last calculated
+// matrices will remain.
+func (r *reedSolomon) WarmInversionCache(maxErasures int) error {
+	if r.tree == nil {
+		return ErrInversionCacheDisabled
+	}
+	if maxErasures > r.ParityShards {
+		maxErasures = r.ParityShards
+	}
+	validIndices := make([]int, r.DataShards)
+	for e := 1; e <= maxErasures; e++ {
+		// Reconstruction only considers the first DataShards+e rows when e shards are missing,
+		// and the last of these must be present.
+		invalidIndices := firstCombination(e)
+		for {
+			v := 0
+			for row, inv := 0, 0; v < r.DataShards; row++ {
+				if inv < e && invalidIndices[inv] == row {
+					inv++
+					continue
+				}
+				validIndices[v] = row
+				v++
+			}
+			_, err := r.getDataDecodeMatrix(validIndices, invalidIndices)
+			if err != nil {
+				return err
+			}
+			if !nextCombination(invalidIndices, r.DataShards+e-1) {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// sharedTree is an inversion tree shared by all encoders with the same matrix and cache settings.
+type sharedTree struct {
+	m    matrix
+	tree *inversionTree
+}
+
+// sharedTreeKey identifies a shared inversion tree.
+type sharedTreeKey struct {
+	fingerprint uint64
+	maxEntries  int
+	eviction    InversionCacheEviction
+}
+
+var sharedTrees = struct {
+	sync.Mutex
+	m map[sharedTreeKey][]sharedTree
+}{m: make(map[sharedTreeKey][]sharedTree)}
+
+// getSharedInversionTree returns the process-wide inversion tree for the matrix m
+// and the cache settings in o, creating it if needed.
+func getSharedInversionTree(dataShards, parityShards int, m matrix, o *options) *inversionTree {
+	key := sharedTreeKey{
+		fingerprint: fingerprint(dataShards, parityShards, 0, m),
+		maxEntries:  o.inversionCacheLimit,
+		eviction:    o.inversionCacheEviction,
+	}
+	sharedTrees.Lock()
+	defer sharedTrees.Unlock()
+	// Compare matrices in case of fingerprint collisions.
+	for _, s := range sharedTrees.m[key] {
+		if s.m.equal(m) {
+			return s.tree
+		}
+	}
+	tree := newInversionTree(dataShards, parityShards)
+	tree.maxEntries = o.inversionCacheLimit
+	tree.eviction = o.inversionCacheEviction
+	sharedTrees.m[key] = append(sharedTrees.m[key], sharedTree{m: m, tree: tree})
+	return tree
+}
+
+// ResetSharedInversionCaches removes all shared inversion caches
+// created with WithSharedInversionCache, so their memory can be reclaimed.
+// Existing encoders keep using their current cache, while new encoders
+// will get a new shared cache.
+func ResetSharedInversionCaches() {
+	sharedTrees.Lock()
+	sharedTrees.m = make(map[sharedTreeKey][]sharedTree)
+	sharedTrees.Unlock()
+}
@@ -0,0 +1,212 @@
+package reedsolomon
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+func TestWarmInversionCache(t *testing.T) {
+	const dataShards, parityShards = 6, 3
+	enc, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.(InversionCacheEncoder).WarmInversionCache(2); err != nil {
+		t.Fatal(err)
+	}
+	// C(6,1) + C(7,2)
+	if got := enc.(InversionCacheEncoder).InversionCacheStats().Entries; got != 6+21 {
+		t.Fatalf("got %d entries, want %d", got, 6+21)
+	}
+	warm := enc.(InversionCacheEncoder).InversionCacheStats().Misses
+
+	shards := randomBytes(dataShards+parityShards, 100)
+	if err := enc.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	for a := 0; a < dataShards+parityShards; a++ {
+		for b := a; b < dataShards+parityShards; b++ {
+			tmp := append([][]byte(nil), shards...)
+			tmp[a], tmp[b] = nil, nil
+			if err := enc.Reconstruct(tmp); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if stats := enc.(InversionCacheEncoder).InversionCacheStats(); stats.Misses != warm {
+		t.Fatalf("%d misses after warming", stats.Misses-warm)
+	}
+}
+
+func TestExportInversionCache(t *testing.T) {
+	const dataShards, parityShards = 6, 3
+	enc, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.(InversionCacheEncoder).WarmInversionCache(3); err != nil {
+		t.Fatal(err)
+	}
+	data, err := enc.(InversionCacheEncoder).ExportInversionCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc2, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc2.(InversionCacheEncoder).ImportInversionCache(data); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := enc.(InversionCacheEncoder).InversionCacheStats().Entries, enc2.(InversionCacheEncoder).InversionCacheStats().Entries; a != b {
+		t.Fatalf("imported %d entries, want %d", b, a)
+	}
+	shards := randomBytes(dataShards+parityShards, 100)
+	if err := enc2.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	want := append([][]byte(nil), shards...)
+	shards[1], shards[4], shards[7] = nil, nil, nil
+	if err := enc2.Reconstruct(shards); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := enc2.Verify(shards)
+	if err != nil || !ok {
+		t.Fatal("verification failed", err)
+	}
+	for i := range shards {
+		if string(shards[i]) != string(want[i]) {
+			t.Fatal("shard", i, "mismatch")
+		}
+	}
+	if stats := enc2.(InversionCacheEncoder).InversionCacheStats(); stats.Misses != 0 {
+		t.Fatalf("%d misses after import", stats.Misses)
+	}
+
+	// Different matrix.
+	enc3, err := New(dataShards, parityShards, testOptions(WithCauchyMatrix())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc3.(InversionCacheEncoder).ImportInversionCache(data); err != ErrConfigMismatch {
+		t.Errorf("expected %v, got %v", ErrConfigMismatch, err)
+	}
+
+	// Corrupted data.
+	bad := append([]byte(nil), data...)
+	bad[len(bad)/2]++
+	if err := enc2.(InversionCacheEncoder).ImportInversionCache(bad); err != ErrInvalidInversionCache {
+		t.Errorf("expected %v, got %v", ErrInvalidInversionCache, err)
+	}
+	if err := enc2.(InversionCacheEncoder).ImportInversionCache(data[:10]); err != ErrInvalidInversionCache {
+		t.Errorf("expected %v, got %v", ErrInvalidInversionCache, err)
+	}
+
+	// Well formed data with a valid checksum, but a wrong matrix or key.
+	resign := func(b []byte) []byte {
+		binary.BigEndian.PutUint32(b[len(b)-4:], crc32.Checksum(b[:len(b)-4], crc32cTable))
+		return b
+	}
+	keyLen := int(data[inversionCacheHeaderSize])
+	bad = append([]byte(nil), data...)
+	bad[inversionCacheHeaderSize+1+keyLen+dataShards+2]++
+	if err := enc2.(InversionCacheEncoder).ImportInversionCache(resign(bad)); err != ErrInvalidInversionCache {
+		t.Errorf("wrong matrix: expected %v, got %v", ErrInvalidInversionCache, err)
+	}
+	bad = append([]byte(nil), data...)
+	bad[inversionCacheHeaderSize+keyLen] = dataShards + parityShards - 1
+	if err := enc2.(InversionCacheEncoder).ImportInversionCache(resign(bad)); err != ErrInvalidInversionCache {
+		t.Errorf("key out of range: expected %v, got %v", ErrInvalidInversionCache, err)
+	}
+
+	enc4, err := New(dataShards, parityShards, testOptions(WithInversionCache(false))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc4.(InversionCacheEncoder).ImportInversionCache(data); err != ErrInversionCacheDisabled {
+		t.Errorf("expected %v, got %v", ErrInversionCacheDisabled, err)
+	}
+}
+
+func TestSharedInversionCache(t *testing.T) {
+	defer ResetSharedInversionCaches()
+	const dataShards, parityShards = 5, 3
+	enc, err := New(dataShards, parityShards, testOptions(WithSharedInversionCache(true))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.(InversionCacheEncoder).WarmInversionCache(parityShards); err != nil {
+		t.Fatal(err)
+	}
+	warmed := enc.(InversionCacheEncoder).InversionCacheStats().Entries
+	if warmed == 0 {
+		t.Fatal("no entries after warming")
+	}
+
+	enc2, err := New(dataShards, parityShards, testOptions(WithSharedInversionCache(true))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := enc2.(InversionCacheEncoder).InversionCacheStats()
+	if before.Entries != warmed {
+		t.Fatalf("shared cache has %d entries, want %d", before.Entries, warmed)
+	}
+	data := make([]byte, 1000)
+	fillRandom(data)
+	shards, err := enc2.Split(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc2.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	shards[0], shards[2] = nil, nil
+	if err := enc2.Reconstruct(shards); err != nil {
+		t.Fatal(err)
+	}
+	if stats := enc2.(InversionCacheEncoder).InversionCacheStats(); stats.Misses != before.Misses || stats.Hits == before.Hits {
+		t.Fatalf("expected only hits, got %+v, before %+v", stats, before)
+	}
+
+	// Different matrix, limit or no sharing must use a separate cache.
+	for _, opts := range [][]Option{
+		{WithSharedInversionCache(true), WithCauchyMatrix()},
+		{WithSharedInversionCache(true), WithInversionCacheLimit(10)},
+		{},
+	} {
+		enc3, err := New(dataShards, parityShards, testOptions(opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := enc3.(InversionCacheEncoder).InversionCacheStats().Entries; got != 0 {
+			t.Errorf("unrelated cache has %d entries", got)
+		}
+	}
+
+	ResetSharedInversionCaches()
+	enc4, err := New(dataShards, parityShards, testOptions(WithSharedInversionCache(true))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := enc4.(InversionCacheEncoder).InversionCacheStats().Entries; got != 0 {
+		t.Errorf("cache has %d entries after reset", got)
+	}
+
+	// LRC encoders share their caches as well.
+	lrc, err := NewLRC(4, 2, 3, testOptions(WithSharedInversionCache(true))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lrc2, err := NewLRC(4, 2, 3, testOptions(WithSharedInversionCache(true))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lrc.(*LRC).global.(*reedSolomon).tree != lrc2.(*LRC).global.(*reedSolomon).tree {
+		t.Error("global LRC encoders do not share cache")
+	}
+	if lrc.(*LRC).localLeft.(*reedSolomon).tree == lrc.(*LRC).localRight.(*reedSolomon).tree {
+		t.Error("local LRC encoders with different matrices share cache")
+	}
+}
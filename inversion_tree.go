@@ -9,18 +9,74 @@ package reedsolomon
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
 )
 
 // The tree uses a Reader-Writer mutex to make it thread-safe
 // when accessing cached matrices and inserting new ones.
+//
+// If maxEntries is set, the least recently or least frequently used
+// matrix is evicted when a new matrix is inserted into a full tree.
 type inversionTree struct {
+	// Accessed atomically, keep first for alignment.
+	tick      uint64
+	hits      uint64
+	misses    uint64
+	evictions uint64
+
 	mutex sync.RWMutex
 	root  inversionNode
+
+	maxEntries int
+	eviction   InversionCacheEviction
+
+	// entries contains all nodes with a cached matrix, except the root.
+	entries []*inversionNode
+	// nodes and childSlots track the size of the tree, excluding the root.
+	nodes      int
+	childSlots int
 }
 
 type inversionNode struct {
+	// used is the last access tick with LRU eviction,
+	// or the number of accesses with LFU eviction.
+	// Accessed atomically, keep first for alignment.
+	used uint64
+
 	matrix   matrix
 	children []*inversionNode
+
+	// key is the invalid indices of the cached matrix.
+	key []int
+	// index is the position of the node in the entries of the tree.
+	index int
+}
+
+// InversionCacheEviction selects which cached matrix is evicted
+// when the inversion cache is full.
+type InversionCacheEviction uint8
+
+const (
+	// EvictLRU evicts the least recently used matrix.
+	EvictLRU InversionCacheEviction = iota
+	// EvictLFU evicts the least frequently used matrix.
+	EvictLFU
+)
+
+// InversionCacheStats contains statistics of the inversion cache of an encoder.
+type InversionCacheStats struct {
+	// Hits is the number of reconstructions that used a cached matrix.
+	Hits uint64
+	// Misses is the number of reconstructions that had to invert a matrix.
+	Misses uint64
+	// Evictions is the number of matrices removed to make room for new ones.
+	Evictions uint64
+	// Entries is the number of matrices currently cached.
+	Entries int
+	// MaxEntries is the maximum number of cached matrices, or 0 if unlimited.
+	MaxEntries int
+	// Bytes is the approximate memory used by the cache.
+	Bytes int
 }
 
 // newInversionTree initializes a tree for storing inverted matrices.
@@ -54,7 +110,23 @@ func (t *inversionTree) GetInvertedMatrix(invalidIndices []int) matrix {
 
 	// Recursively search for the inverted matrix in the tree, passing in
 	// 0 as the parent index as we start at the root of the tree.
-	return t.root.getInvertedMatrix(invalidIndices, 0)
+	node := t.root.getInvertedMatrix(invalidIndices, 0)
+	if node == nil || node.matrix == nil {
+		atomic.AddUint64(&t.misses, 1)
+		return nil
+	}
+	atomic.AddUint64(&t.hits, 1)
+	t.touch(node)
+	return node.matrix
+}
+
+// touch records an access to a node.
+func (t *inversionTree) touch(n *inversionNode) {
+	if t.eviction == EvictLFU {
+		atomic.AddUint64(&n.used, 1)
+		return
+	}
+	atomic.StoreUint64(&n.used, atomic.AddUint64(&t.tick, 1))
 }
 
 // errAlreadySet is returned if the root node matrix is overwritten
@@ -85,12 +157,72 @@ func (t *inversionTree) InsertInvertedMatrix(invalidIndices []int, matrix matrix
 	// Recursively create nodes for the inverted matrix in the tree until
 	// we reach the node to insert the matrix to.  We start by passing in
 	// 0 as the parent index as we start at the root of the tree.
-	t.root.insertInvertedMatrix(invalidIndices, matrix, shards, 0)
+	if node := t.root.getInvertedMatrix(invalidIndices, 0); node != nil && node.matrix != nil {
+		// Already inserted by another reconstruction.
+		node.matrix = matrix
+		return nil
+	}
 
+	// Make room before adding the new entry.
+	for t.maxEntries > 0 && len(t.entries) >= t.maxEntries {
+		t.evict()
+	}
+
+	node := t.root.insertInvertedMatrix(t, invalidIndices, shards, 0)
+	node.matrix = matrix
+	node.key = append([]int(nil), invalidIndices...)
+	node.index = len(t.entries)
+	node.used = 0
+	t.entries = append(t.entries, node)
+	t.touch(node)
 	return nil
 }
 
-func (n *inversionNode) getInvertedMatrix(invalidIndices []int, parent int) matrix {
+// evict removes the least recently or least frequently used matrix from the tree.
+// The write lock must be held.
+func (t *inversionTree) evict() {
+	var victim *inversionNode
+	for _, e := range t.entries {
+		if victim == nil || atomic.LoadUint64(&e.used) < atomic.LoadUint64(&victim.used) {
+			victim = e
+		}
+	}
+	if victim == nil {
+		return
+	}
+	last := t.entries[len(t.entries)-1]
+	last.index = victim.index
+	t.entries[victim.index] = last
+	t.entries = t.entries[:len(t.entries)-1]
+	victim.matrix = nil
+	t.root.removeEmpty(t, victim.key, 0)
+	victim.key = nil
+	t.evictions++
+}
+
+// Stats returns statistics of the tree.
+func (t *inversionTree) Stats() InversionCacheStats {
+	if t == nil {
+		return InversionCacheStats{}
+	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	const ptrSize = 4 << (^uintptr(0) >> 63)
+	const sliceSize = 3 * ptrSize
+	size := len(t.root.matrix)
+	return InversionCacheStats{
+		Hits:       atomic.LoadUint64(&t.hits),
+		Misses:     atomic.LoadUint64(&t.misses),
+		Evictions:  t.evictions,
+		Entries:    len(t.entries),
+		MaxEntries: t.maxEntries,
+		// Each node has a header, children and possibly a matrix with key.
+		Bytes: t.nodes*(8+2*sliceSize+sliceSize+ptrSize) + t.childSlots*ptrSize +
+			len(t.entries)*(size*(size+sliceSize)+size*ptrSize),
+	}
+}
+
+func (n *inversionNode) getInvertedMatrix(invalidIndices []int, parent int) *inversionNode {
 	// Get the child node to search next from the list of children.  The
 	// list of children starts relative to the parent index passed in
 	// because the indices of invalid rows is sorted (by default).  As we
@@ -118,10 +250,12 @@ func (n *inversionNode) getInvertedMatrix(invalidIndices []int, parent int) matr
 	// node.  Return it, however keep in mind that the matrix could still be
 	// nil because intermediary nodes in the tree are created sometimes with
 	// their inversion matrices uninitialized.
-	return node.matrix
+	return node
 }
 
-func (n *inversionNode) insertInvertedMatrix(invalidIndices []int, matrix matrix, shards, parent int) {
+// insertInvertedMatrix returns the node for the invalid indices,
+// creating it and any intermediary nodes as needed.
+func (n *inversionNode) insertInvertedMatrix(t *inversionTree, invalidIndices []int, shards, parent int) *inversionNode {
 	// As above, get the child node to search next from the list of children.
 	// The list of children starts relative to the parent index passed in
 	// because the indices of invalid rows is sorted (by default).  As we
@@ -145,6 +279,8 @@ func (n *inversionNode) insertInvertedMatrix(invalidIndices []int, matrix matrix
 		// Insert the new node into the tree at the first index relative
 		// to the parent index that was given in this recursive call.
 		n.children[firstIndex-parent] = node
+		t.nodes++
+		t.childSlots += len(node.children)
 	}
 
 	// If there's more than one invalid index left in the list we should
@@ -155,10 +291,33 @@ func (n *inversionNode) insertInvertedMatrix(invalidIndices []int, matrix matrix
 		// the invalid indices with the first index popped off the front.
 		// Also the total number of shards and parent index are passed down
 		// which is equal to the first index plus one.
-		node.insertInvertedMatrix(invalidIndices[1:], matrix, shards, firstIndex+1)
-	} else {
-		// If there aren't any more invalid indices to search, we've found our
-		// node.  Cache the inverted matrix in this node.
-		node.matrix = matrix
+		return node.insertInvertedMatrix(t, invalidIndices[1:], shards, firstIndex+1)
+	}
+	// If there aren't any more invalid indices to search, we've found our
+	// node, where the inverted matrix will be cached.
+	return node
+}
+
+// removeEmpty removes nodes on the path of the invalid indices
+// that have neither a matrix nor any children.
+// Returns true if n itself is empty after the removal.
+func (n *inversionNode) removeEmpty(t *inversionTree, invalidIndices []int, parent int) bool {
+	if len(invalidIndices) > 0 {
+		firstIndex := invalidIndices[0]
+		node := n.children[firstIndex-parent]
+		if node != nil && node.removeEmpty(t, invalidIndices[1:], firstIndex+1) {
+			n.children[firstIndex-parent] = nil
+			t.nodes--
+			t.childSlots -= len(node.children)
+		}
+	}
+	if n.matrix != nil {
+		return false
+	}
+	for _, c := range n.children {
+		if c != nil {
+			return false
+		}
 	}
+	return true
 }
@@ -123,3 +123,60 @@ func TestDoubleInsertInvertedMatrix(t *testing.T) {
 		t.Fatal(matrix.String(), "!=", cachedMatrix.String())
 	}
 }
+
+func TestInversionTreeEviction(t *testing.T) {
+	for _, policy := range []InversionCacheEviction{EvictLRU, EvictLFU} {
+		tree := newInversionTree(3, 3)
+		tree.maxEntries = 2
+		tree.eviction = policy
+		m, _ := identityMatrix(3)
+
+		for _, key := range [][]int{{0}, {1, 2}} {
+			if err := tree.InsertInvertedMatrix(key, m, 6); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Use {1, 2} more and most recently.
+		tree.GetInvertedMatrix([]int{0})
+		tree.GetInvertedMatrix([]int{1, 2})
+		tree.GetInvertedMatrix([]int{1, 2})
+		if tree.GetInvertedMatrix([]int{2}) != nil {
+			t.Fatal("unexpected cached matrix")
+		}
+
+		if err := tree.InsertInvertedMatrix([]int{1}, m, 6); err != nil {
+			t.Fatal(err)
+		}
+		if tree.GetInvertedMatrix([]int{0}) != nil {
+			t.Fatal(policy, ": expected {0} to be evicted")
+		}
+		if tree.GetInvertedMatrix([]int{1, 2}) == nil || tree.GetInvertedMatrix([]int{1}) == nil {
+			t.Fatal(policy, ": expected {1, 2} and {1} to be cached")
+		}
+
+		stats := tree.Stats()
+		want := InversionCacheStats{Hits: 5, Misses: 2, Evictions: 1, Entries: 2, MaxEntries: 2}
+		stats.Bytes = 0
+		if stats != want {
+			t.Fatalf("%v: got %+v, want %+v", policy, stats, want)
+		}
+
+		// Evicting everything must leave only the root.
+		tree.maxEntries = 1
+		if err := tree.InsertInvertedMatrix([]int{0, 1, 2}, m, 6); err != nil {
+			t.Fatal(err)
+		}
+		tree.maxEntries = 0
+		tree.mutex.Lock()
+		tree.evict()
+		tree.mutex.Unlock()
+		for i, c := range tree.root.children {
+			if c != nil {
+				t.Fatal(policy, ": child", i, "was not removed")
+			}
+		}
+		if tree.nodes != 0 || tree.childSlots != 0 || len(tree.entries) != 0 {
+			t.Fatalf("%v: tree not empty: %d nodes, %d slots, %d entries", policy, tree.nodes, tree.childSlots, len(tree.entries))
+		}
+	}
+}
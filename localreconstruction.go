@@ -282,7 +282,8 @@ func (l *LRC) Verify(shards [][]byte) (ret bool, err error) {
 		globalData[l.dataShards+i] = shards[l.dataShards+i+1]
 	}
 	sencondParity := shards[l.dataShards+1]
-	globalData[l.dataShards] = make([]byte, len(shards[l.dataShards+1]))
+	globalData[l.dataShards] = l.options.alloc(len(shards[l.dataShards+1]))
+	defer l.options.free(globalData[l.dataShards])
 	copy(globalData[l.dataShards], shards[l.dataShards+1])
 	galMulSliceXor(1, shards[l.dataShards], globalData[l.dataShards], &l.options)
 	ret, err = l.global.Verify(globalData)
@@ -8,6 +8,7 @@
 package reedsolomon
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"strconv"
@@ -151,6 +152,19 @@ func (m matrix) SameSize(n matrix) error {
 	return nil
 }
 
+// equal returns true if both matrices have the same size and content.
+func (m matrix) equal(n matrix) bool {
+	if m.SameSize(n) != nil {
+		return false
+	}
+	for i := range m {
+		if !bytes.Equal(m[i], n[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 // SubMatrix returns a part of this matrix. Data is copied.
 func (m matrix) SubMatrix(rmin, cmin, rmax, cmax int) (matrix, error) {
 	result, err := newMatrix(rmax-rmin, cmax-cmin)
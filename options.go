@@ -20,11 +20,24 @@ type options struct {
 	useCauchy                             bool
 	fastOneParity                         bool
 	inversionCache                        bool
+	sharedInversionCache                  bool
+	validateMatrix                        bool
+	skipZeroBlocks                        bool
+
+	customMatrix [][]byte
+
+	inversionCacheLimit    int
+	inversionCacheEviction InversionCacheEviction
+
+	allocator Allocator
 
 	// stream options
 	concReads  bool
 	concWrites bool
 	streamBS   int
+	pipeline   int
+
+	streamParallelism int
 }
 
 var defaultOptions = options{
@@ -32,6 +45,7 @@ var defaultOptions = options{
 	minSplitSize:   -1,
 	fastOneParity:  false,
 	inversionCache: true,
+	validateMatrix: true,
 
 	// Detect CPU capabilities.
 	useSSSE3:  cpuid.CPU.Supports(cpuid.SSSE3),
@@ -120,6 +134,42 @@ func WithInversionCache(enabled bool) Option {
 	}
 }
 
+// WithSharedInversionCache will make encoders with identical encoding matrices
+// and inversion cache settings share a single process-wide inversion cache.
+// This avoids inverting the same matrices again in every encoder when many
+// encoders are created for the same layout. This also applies to the encoders inside NewLRC.
+// The shared caches are safe for concurrent use and are kept until
+// ResetSharedInversionCaches is called.
+// Default: Disabled.
+func WithSharedInversionCache(enabled bool) Option {
+	return func(o *options) {
+		o.sharedInversionCache = enabled
+	}
+}
+
+// WithInversionCacheLimit limits the number of matrices kept in the inversion cache.
+// When the limit is reached, a matrix is evicted before a new one is added,
+// as selected with WithInversionCacheEviction.
+// Each matrix uses DataShards*DataShards bytes.
+// If n <= 0, the cache is unlimited, which is the default.
+func WithInversionCacheLimit(n int) Option {
+	return func(o *options) {
+		if n < 0 {
+			n = 0
+		}
+		o.inversionCacheLimit = n
+	}
+}
+
+// WithInversionCacheEviction selects which matrix is evicted from the
+// inversion cache when the limit set with WithInversionCacheLimit is reached.
+// Default: EvictLRU.
+func WithInversionCacheEviction(policy InversionCacheEviction) Option {
+	return func(o *options) {
+		o.inversionCacheEviction = policy
+	}
+}
+
 // WithStreamBlockSize allows to set a custom block size per round of reads/writes.
 // If not set, any shard size set with WithAutoGoroutines will be used.
 // If WithAutoGoroutines is also unset, 4MB will be used.
@@ -130,6 +180,52 @@ func WithStreamBlockSize(n int) Option {
 	}
 }
 
+// WithStreamPipeline will make stream Encode and Reconstruct keep up to
+// 'blocks' blocks in flight, so reading the next block, coding the current
+// block and writing the previous block happens at the same time.
+// Each block in flight uses (DataShards+ParityShards)*stream block size bytes.
+// Values below 2 disable pipelining, which is the default.
+// Ignored if not used on stream.
+func WithStreamPipeline(blocks int) Option {
+	return func(o *options) {
+		o.pipeline = blocks
+	}
+}
+
+// WithSkipZeroBlocks will make the encoder check data for blocks of zeros
+// and leave them out of the calculations, since they don't contribute to the parity.
+// This speeds up encoding of sparse data and of the zero padding added by Split,
+// at the cost of reading the data an extra time.
+// Data is checked in blocks of 4KB, and parity where all data is zero is set to zero
+// without any calculations. For Update, data shards that are unchanged are skipped.
+// This also applies to every block of a stream encoder.
+// Default is disabled.
+func WithSkipZeroBlocks(enabled bool) Option {
+	return func(o *options) {
+		o.skipZeroBlocks = enabled
+	}
+}
+
+// WithAllocator will make the encoder use the supplied allocator for
+// the shards it creates, see Allocator for details.
+// Use AllocAligned to get aligned shards.
+// If a is nil, memory is allocated with make, which is the default.
+func WithAllocator(a Allocator) Option {
+	return func(o *options) {
+		o.allocator = a
+	}
+}
+
+// WithStreamParallelism sets the number of shards that stream SplitAt and JoinAt
+// process concurrently. Each shard being processed uses a buffer of the stream block size.
+// If n <= 0, all data shards are processed concurrently, which is the default.
+// Ignored if not used on stream.
+func WithStreamParallelism(n int) Option {
+	return func(o *options) {
+		o.streamParallelism = n
+	}
+}
+
 func withSSSE3(enabled bool) Option {
 	return func(o *options) {
 		o.useSSSE3 = enabled
@@ -184,3 +280,35 @@ func WithFastOneParityMatrix() Option {
 		o.fastOneParity = true
 	}
 }
+
+// WithCustomMatrix causes the encoder to use the supplied encoding matrix.
+// The matrix must have DataShards columns and either ParityShards rows,
+// which will be used as the parity part of the matrix, or DataShards+ParityShards
+// rows where the top DataShards rows form the identity matrix.
+//
+// By default New will check that every combination of DataShards rows
+// can be inverted, so any set of lost shards up to ParityShards can be recovered.
+// This check can be disabled with WithMatrixValidation(false).
+//
+// The matrix is copied, so it can be modified after the encoder has been created.
+// Only used by New, this overrides WithPAR1Matrix, WithCauchyMatrix and
+// WithFastOneParityMatrix.
+func WithCustomMatrix(customMatrix [][]byte) Option {
+	return func(o *options) {
+		o.customMatrix = customMatrix
+	}
+}
+
+// WithMatrixValidation controls whether New checks that a matrix supplied
+// with WithCustomMatrix allows recovery from any combination of lost shards.
+// There are C(DataShards+ParityShards, ParityShards)-1 submatrices to check,
+// which grows combinatorially with the shard count.
+// If there are more than 1<<20 (for example 20+10 shards),
+// New returns ErrMatrixValidationTooLarge instead of checking,
+// and validation must be disabled to use the matrix.
+// Enabled by default.
+func WithMatrixValidation(enabled bool) Option {
+	return func(o *options) {
+		o.validateMatrix = enabled
+	}
+}
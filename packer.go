@@ -0,0 +1,324 @@
+package reedsolomon
+
+import (
+	"errors"
+)
+
+// ErrObjectTooLarge is returned by Packer.Add if an object
+// is larger than the data of a stripe.
+var ErrObjectTooLarge = errors.New("object larger than stripe data")
+
+// StripeEncoder encodes the shards of a stripe.
+// The encoders returned by New and NewLRC implement it,
+// which can be checked with a type assertion.
+type StripeEncoder interface {
+	Encode(shards [][]byte) error
+	Config() Config
+}
+
+// PackedObject is the location of an object packed by a Packer.
+type PackedObject struct {
+	// Stripe is the number of the stripe, starting at 0.
+	Stripe int `json:"stripe"`
+
+	// Offset is the offset of the object in the data of the stripe.
+	// The data of a stripe is the data shards laid out one after another.
+	Offset int `json:"offset"`
+
+	// Length is the length of the object.
+	Length int `json:"length"`
+}
+
+// Packer packs many small objects into shared stripes of fixed size shards.
+//
+// Objects are appended to the data of the current stripe, which is the
+// data shards laid out one after another, so small objects only span one
+// or a few data shards.
+// When an object doesn't fit in the current stripe, the stripe is padded
+// with zeros, encoded and handed to the write function, and a new stripe is started.
+//
+// The location of every object is recorded in the index,
+// and a single object can be read with ReadObject using only the data shards it spans.
+//
+// A Packer is not safe for concurrent use.
+type Packer struct {
+	enc         StripeEncoder
+	dataShards  int
+	totalShards int
+	shardSize   int
+	write       func(stripe int, shards [][]byte) error
+
+	cur    []byte
+	used   int
+	stripe int
+	index  []PackedObject
+}
+
+// NewPacker creates a Packer that packs objects into stripes with shards of
+// shardSize bytes, encoded with enc.
+// Every stripe is passed to write once it is encoded,
+// and write may keep the shards.
+func NewPacker(enc StripeEncoder, shardSize int, write func(stripe int, shards [][]byte) error) (*Packer, error) {
+	if enc == nil || write == nil {
+		return nil, ErrInvalidInput
+	}
+	if shardSize <= 0 {
+		return nil, ErrShardNoData
+	}
+	c := enc.Config()
+	return &Packer{
+		enc:         enc,
+		dataShards:  c.DataShards,
+		totalShards: c.DataShards + c.LocalShards + c.ParityShards,
+		shardSize:   shardSize,
+		write:       write,
+	}, nil
+}
+
+// Add appends obj to the current stripe and returns its location.
+// If obj doesn't fit in the current stripe, the current stripe is written first.
+// If the current stripe is full after adding obj, it is written right away.
+// Empty objects are allowed.
+//
+// Objects larger than the data of a stripe return ErrObjectTooLarge.
+// If writing a stripe fails, the error is returned and obj is not added.
+func (p *Packer) Add(obj []byte) (PackedObject, error) {
+	stripeSize := p.dataShards * p.shardSize
+	if len(obj) > stripeSize {
+		return PackedObject{}, ErrObjectTooLarge
+	}
+	if p.used+len(obj) > stripeSize {
+		if err := p.Flush(); err != nil {
+			return PackedObject{}, err
+		}
+	}
+	if p.cur == nil {
+		p.cur = make([]byte, 0, p.totalShards*p.shardSize)
+	}
+	loc := PackedObject{Stripe: p.stripe, Offset: p.used, Length: len(obj)}
+	p.cur = append(p.cur, obj...)
+	p.used += len(obj)
+	p.index = append(p.index, loc)
+	if p.used == stripeSize {
+		if err := p.Flush(); err != nil {
+			p.remove(loc)
+			return PackedObject{}, err
+		}
+	}
+	return loc, nil
+}
+
+// remove removes the last added object at loc from the current stripe.
+func (p *Packer) remove(loc PackedObject) {
+	// Keep the stripe zero padded.
+	added := p.cur[loc.Offset:p.used]
+	for i := range added {
+		added[i] = 0
+	}
+	p.cur = p.cur[:loc.Offset]
+	if loc.Offset == 0 {
+		p.cur = nil
+	}
+	p.used = loc.Offset
+	p.index = p.index[:len(p.index)-1]
+}
+
+// Flush pads, encodes and writes the current stripe,
+// if any objects have been added to it.
+// The next object will be added to a new stripe.
+func (p *Packer) Flush() error {
+	if p.cur == nil {
+		return nil
+	}
+	// The stripe was allocated zeroed, so it is already padded.
+	buf := p.cur[:cap(p.cur)]
+	shards := make([][]byte, p.totalShards)
+	for i := range shards {
+		shards[i] = buf[i*p.shardSize : (i+1)*p.shardSize : (i+1)*p.shardSize]
+	}
+	if err := p.enc.Encode(shards); err != nil {
+		return err
+	}
+	if err := p.write(p.stripe, shards); err != nil {
+		return err
+	}
+	p.cur = nil
+	p.used = 0
+	p.stripe++
+	return nil
+}
+
+// Index returns the location of every added object, in the order they were added.
+// Objects in the current stripe are included even if the stripe hasn't been written.
+func (p *Packer) Index() []PackedObject {
+	return append([]PackedObject(nil), p.index...)
+}
+
+// Spans returns the indexes of the data shards that contain the object at loc.
+// Only these shards are needed by ReadObject when they are all present.
+func (p *Packer) Spans(loc PackedObject) []int {
+	if loc.Length <= 0 {
+		return nil
+	}
+	first := loc.Offset / p.shardSize
+	last := (loc.Offset + loc.Length - 1) / p.shardSize
+	idx := make([]int, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		idx = append(idx, i)
+	}
+	return idx
+}
+
+// ReadObject returns the object at loc from the shards of its stripe.
+//
+// 'shards' must contain every shard of the stripe, with nil for missing
+// shards or shards that have not been loaded.
+// If all data shards the object spans are present, only these are read.
+// Otherwise the missing part is reconstructed from the other shards.
+// If the object is within a single shard, only the bytes of the shards
+// that overlap the object are reconstructed. If it spans several shards,
+// the missing data shards it spans are reconstructed completely.
+// For LRC encoders, local parity is used when possible.
+// The shards are not modified.
+//
+// If loc is outside the stripe, ErrInvalidRange is returned.
+// If there are too few shards to reconstruct the object,
+// ErrTooFewShards is returned.
+func (p *Packer) ReadObject(loc PackedObject, shards [][]byte) ([]byte, error) {
+	if len(shards) != p.totalShards {
+		return nil, ErrTooFewShards
+	}
+	if loc.Offset < 0 || loc.Length < 0 || loc.Offset+loc.Length > p.dataShards*p.shardSize {
+		return nil, ErrInvalidRange
+	}
+	for _, shard := range shards {
+		if len(shard) != 0 && len(shard) != p.shardSize {
+			return nil, ErrShardSize
+		}
+	}
+	spans := p.Spans(loc)
+	missing := false
+	for _, i := range spans {
+		if len(shards[i]) == 0 {
+			missing = true
+		}
+	}
+	dst := make([]byte, 0, loc.Length)
+	if !missing {
+		for _, i := range spans {
+			dst = append(dst, p.shardPart(loc, i, shards[i], 0)...)
+		}
+		return dst, nil
+	}
+
+	// Reconstruct the part of the shards that overlaps the object.
+	lo, hi := 0, p.shardSize
+	if len(spans) == 1 {
+		lo = loc.Offset - spans[0]*p.shardSize
+		hi = lo + loc.Length
+	}
+	window := make([][]byte, len(shards))
+	for i := range shards {
+		if len(shards[i]) != 0 {
+			window[i] = shards[i][lo:hi]
+		}
+	}
+	var err error
+	switch enc := p.enc.(type) {
+	case *reedSolomon:
+		err = enc.reconstructDataRows(window, spans)
+	case *LRC:
+		err = enc.reconstructWindow(window)
+	default:
+		if e, ok := p.enc.(Encoder); ok {
+			err = e.ReconstructData(window)
+		} else {
+			err = ErrInvalidInput
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range spans {
+		dst = append(dst, p.shardPart(loc, i, window[i], lo)...)
+	}
+	if rs, ok := p.enc.(*reedSolomon); ok {
+		// The reconstructed rows were allocated by reconstructDataRows.
+		for _, i := range spans {
+			if len(shards[i]) == 0 {
+				rs.o.free(window[i])
+			}
+		}
+	}
+	return dst, nil
+}
+
+// shardPart returns the part of data shard idx that contains the object at loc.
+// 'shard' contains the shard starting at offset lo.
+func (p *Packer) shardPart(loc PackedObject, idx int, shard []byte, lo int) []byte {
+	start := loc.Offset - idx*p.shardSize
+	if start < 0 {
+		start = 0
+	}
+	end := loc.Offset + loc.Length - idx*p.shardSize
+	if end > p.shardSize {
+		end = p.shardSize
+	}
+	return shard[start-lo : end-lo]
+}
+
+// reconstructDataRows reconstructs the missing data shards in rows.
+// Missing shards must have zero length, and are replaced by new slices.
+// Other missing shards are not reconstructed.
+func (r *reedSolomon) reconstructDataRows(shards [][]byte, rows []int) error {
+	if len(shards) != r.Shards {
+		return ErrTooFewShards
+	}
+	if err := checkShards(shards, true); err != nil {
+		return err
+	}
+	size := shardSize(shards)
+	inputs := make([][]byte, 0, r.DataShards)
+	validIndices := make([]int, 0, r.DataShards)
+	invalidIndices := make([]int, 0)
+	for i := 0; i < r.Shards && len(validIndices) < r.DataShards; i++ {
+		if len(shards[i]) == 0 {
+			invalidIndices = append(invalidIndices, i)
+			continue
+		}
+		inputs = append(inputs, shards[i])
+		validIndices = append(validIndices, i)
+	}
+	if len(validIndices) < r.DataShards {
+		return ErrTooFewShards
+	}
+	dataDecodeMatrix, err := r.getDataDecodeMatrix(validIndices, invalidIndices)
+	if err != nil {
+		return err
+	}
+	var matrixRows, outputs [][]byte
+	for _, row := range rows {
+		if len(shards[row]) != 0 {
+			continue
+		}
+		shards[row] = r.o.alloc(size)
+		matrixRows = append(matrixRows, dataDecodeMatrix[row])
+		outputs = append(outputs, shards[row])
+	}
+	if len(outputs) > 0 {
+		r.codeSomeShards(matrixRows, inputs, outputs, len(outputs[0]))
+	}
+	return nil
+}
+
+// reconstructWindow reconstructs the missing shards like GlobalRepair,
+// which tries local repair first.
+// The local parity shards are copied, since GlobalRepair modifies them.
+func (l *LRC) reconstructWindow(shards [][]byte) error {
+	for i := l.dataShards; i < l.dataShards+l.localShards; i++ {
+		if len(shards[i]) != 0 {
+			shards[i] = append([]byte(nil), shards[i]...)
+		}
+	}
+	return l.GlobalRepair(shards)
+}
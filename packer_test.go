@@ -0,0 +1,206 @@
+package reedsolomon
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestPacker(t *testing.T) {
+	const shardSize = 1000
+	rs, err := New(10, 3, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lrc, err := NewLRC(4, 2, 3, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, enc := range []StripeEncoder{rs.(StripeEncoder), lrc.(StripeEncoder)} {
+		c := enc.Config()
+		total := c.DataShards + c.LocalShards + c.ParityShards
+		var stripes, origs [][][]byte
+		p, err := NewPacker(enc, shardSize, func(stripe int, shards [][]byte) error {
+			if stripe != len(stripes) {
+				t.Fatalf("got stripe %d, want %d", stripe, len(stripes))
+			}
+			stripes = append(stripes, shards)
+			orig := make([][]byte, len(shards))
+			for i := range shards {
+				orig[i] = append([]byte(nil), shards[i]...)
+			}
+			origs = append(origs, orig)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rng := rand.New(rand.NewSource(0))
+		var objects [][]byte
+		for i := 0; i < 200; i++ {
+			obj := make([]byte, rng.Intn(700))
+			if i%50 == 0 {
+				obj = make([]byte, c.DataShards*shardSize)
+			}
+			rng.Read(obj)
+			loc, err := p.Add(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loc.Length != len(obj) {
+				t.Fatalf("got length %d, want %d", loc.Length, len(obj))
+			}
+			objects = append(objects, obj)
+		}
+		if _, err := p.Add(make([]byte, c.DataShards*shardSize+1)); err != ErrObjectTooLarge {
+			t.Errorf("expected %v, got %v", ErrObjectTooLarge, err)
+		}
+		if err := p.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		for _, shards := range stripes {
+			ok, err := enc.(interface {
+				Verify([][]byte) (bool, error)
+			}).Verify(shards)
+			if err != nil || !ok {
+				t.Fatal("stripe verification failed", err)
+			}
+		}
+
+		index := p.Index()
+		if len(index) != len(objects) {
+			t.Fatalf("got %d index entries, want %d", len(index), len(objects))
+		}
+		for i, loc := range index {
+			stripe := stripes[loc.Stripe]
+
+			// Only the spanned data shards.
+			shards := make([][]byte, total)
+			spans := p.Spans(loc)
+			for _, idx := range spans {
+				shards[idx] = stripe[idx]
+			}
+			got, err := p.ReadObject(loc, shards)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, objects[i]) {
+				t.Fatalf("object %d: mismatch", i)
+			}
+			if len(spans) == 0 {
+				continue
+			}
+
+			// Lose the first spanned shard.
+			shards = make([][]byte, total)
+			copy(shards, stripe)
+			shards[spans[0]] = nil
+			lost := spans[0]
+			shards[(lost+1)%c.DataShards] = nil
+			got, err = p.ReadObject(loc, shards)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, objects[i]) {
+				t.Fatalf("object %d: reconstructed mismatch", i)
+			}
+			for j := range shards {
+				if !bytes.Equal(stripe[j], origs[loc.Stripe][j]) {
+					t.Fatalf("object %d: shard %d was modified", i, j)
+				}
+			}
+		}
+
+		// Too few shards.
+		loc := index[1]
+		shards := make([][]byte, total)
+		shards[total-1] = stripes[loc.Stripe][total-1]
+		if _, err := p.ReadObject(loc, shards); err != ErrTooFewShards {
+			t.Errorf("expected %v, got %v", ErrTooFewShards, err)
+		}
+		if _, err := p.ReadObject(PackedObject{Offset: c.DataShards * shardSize, Length: 1}, shards); err != ErrInvalidRange {
+			t.Errorf("expected %v, got %v", ErrInvalidRange, err)
+		}
+	}
+}
+
+func TestPackerWriteError(t *testing.T) {
+	const shardSize = 100
+	enc, err := New(4, 2, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errWrite := errors.New("write failed")
+	fail := true
+	var stripes [][][]byte
+	p, err := NewPacker(enc.(StripeEncoder), shardSize, func(stripe int, shards [][]byte) error {
+		if fail {
+			return errWrite
+		}
+		written := make([][]byte, len(shards))
+		for i := range shards {
+			written[i] = append([]byte(nil), shards[i]...)
+		}
+		stripes = append(stripes, written)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := [][]byte{make([]byte, 150), make([]byte, 4*shardSize-150), make([]byte, 4*shardSize)}
+	for _, obj := range objects {
+		fillRandom(obj)
+	}
+
+	// Adding an object that fills the stripe fails, and the object is not added.
+	if _, err := p.Add(objects[0]); err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range objects[1:] {
+		if _, err := p.Add(obj); err != errWrite {
+			t.Fatalf("expected %v, got %v", errWrite, err)
+		}
+		if len(p.Index()) != 1 {
+			t.Fatalf("got %d index entries, want 1", len(p.Index()))
+		}
+	}
+
+	fail = false
+	for _, obj := range objects[1:] {
+		if _, err := p.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := p.Index()
+	if len(index) != len(objects) || len(stripes) != 2 {
+		t.Fatalf("got %d objects in %d stripes, want %d in 2", len(index), len(stripes), len(objects))
+	}
+	for _, shards := range stripes {
+		ok, err := enc.Verify(shards)
+		if err != nil || !ok {
+			t.Fatal("stripe verification failed", err)
+		}
+	}
+	for i, loc := range index {
+		got, err := p.ReadObject(loc, stripes[loc.Stripe])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, objects[i]) {
+			t.Fatalf("object %d: mismatch", i)
+		}
+	}
+
+	// A failed object that would fill an empty stripe leaves nothing to flush.
+	fail = true
+	if _, err := p.Add(objects[2]); err != errWrite {
+		t.Fatalf("expected %v, got %v", errWrite, err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Index()) != len(objects) {
+		t.Fatalf("got %d index entries, want %d", len(p.Index()), len(objects))
+	}
+}
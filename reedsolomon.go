@@ -108,6 +108,158 @@ type Encoder interface {
 	Join(dst io.Writer, shards [][]byte, outSize int) error
 }
 
+// ChecksumEncoder is implemented by encoders returned by New
+// that can calculate and use per-block checksums.
+// Use a type assertion on an Encoder to access it.
+type ChecksumEncoder interface {
+	// EncodeChecksums encodes parity like Encode and returns CRC32C checksums
+	// of every block of blockSize bytes of all data and parity shards.
+	// The checksums can be stored with the shards and used with ReconstructVerified.
+	EncodeChecksums(shards [][]byte, blockSize int) (*Checksums, error)
+
+	// SplitChecksums splits data like Split, encodes the parity and
+	// returns the shards with checksums of every block of blockSize bytes,
+	// as EncodeChecksums.
+	SplitChecksums(data []byte, blockSize int) ([][]byte, *Checksums, error)
+
+	// ReconstructVerified will recreate missing shards like Reconstruct,
+	// but will also treat blocks that don't match their checksums as missing.
+	// Only blocks that match their checksums are used as input, and
+	// corrupted blocks are overwritten with the reconstructed data.
+	//
+	// If more than ParityShards blocks at the same offset are bad,
+	// ErrTooFewShards will be returned.
+	ReconstructVerified(shards [][]byte, sums *Checksums) error
+}
+
+// FingerprintEncoder is implemented by encoders returned by New
+// that can derive parity shard fingerprints.
+// Use a type assertion on an Encoder to access it.
+type FingerprintEncoder interface {
+	// ParityFingerprints returns the expected fingerprints of the parity shards,
+	// given the fingerprints of the data shards created with NewShardFingerprint.
+	// This allows checking parity shards without access to the data shards.
+	ParityFingerprints(data []ShardFingerprint) ([]ShardFingerprint, error)
+}
+
+// InversionCacheEncoder is implemented by encoders returned by New
+// that give access to the inversion cache.
+// Use a type assertion on an Encoder to access it.
+type InversionCacheEncoder interface {
+	// InversionCacheStats returns statistics of the inversion cache.
+	// If the cache is disabled, all values are zero.
+	InversionCacheStats() InversionCacheStats
+
+	// ExportInversionCache returns the cached decoding matrices in
+	// a compact binary format, to be loaded with ImportInversionCache.
+	ExportInversionCache() ([]byte, error)
+
+	// ImportInversionCache loads decoding matrices exported by an encoder
+	// with the same configuration. ErrConfigMismatch is returned if the
+	// configuration is different.
+	ImportInversionCache(data []byte) error
+
+	// WarmInversionCache calculates and caches the decoding matrices for every
+	// combination of up to maxErasures missing shards.
+	WarmInversionCache(maxErasures int) error
+}
+
+// ConfigEncoder is implemented by encoders returned by New and NewLRC
+// that can return their configuration.
+// Use a type assertion on an Encoder or LRCEncoder to access it.
+type ConfigEncoder interface {
+	// Config returns the configuration of the encoder.
+	// It can be used with NewFromConfig to create an identical encoder.
+	Config() Config
+}
+
+// RangeEncoder is implemented by encoders returned by New
+// that can encode and reconstruct a range of the shards.
+// Use a type assertion on an Encoder to access it.
+type RangeEncoder interface {
+	// EncodeRange will update parity like Encode, but only for the
+	// 'length' bytes starting at 'offset' in every shard.
+	// Parity outside the range is not modified.
+	// If the range is outside the shards, ErrInvalidRange is returned.
+	EncodeRange(shards [][]byte, offset, length int) error
+
+	// ReconstructRange will recreate the missing shards like Reconstruct,
+	// but only the 'length' bytes starting at 'offset'.
+	//
+	// You indicate that a shard is missing by setting it to nil or zero-length.
+	// If a missing shard has capacity for the full shard size,
+	// it is resliced to the full size and only the range is overwritten,
+	// so other parts of the shard are kept.
+	// Otherwise a new zero filled []byte will be allocated.
+	//
+	// If the range is outside the shards, ErrInvalidRange is returned.
+	ReconstructRange(shards [][]byte, offset, length int) error
+}
+
+// PreservingUpdater is implemented by encoders returned by New
+// that can update parity without modifying the data shards.
+// Use a type assertion on an Encoder to access it.
+type PreservingUpdater interface {
+	// UpdatePreserve updates parity like Update, but leaves both the old
+	// and the new data shards unchanged.
+	UpdatePreserve(shards [][]byte, newDatashards [][]byte) error
+
+	// ParityDelta returns the changes to the parity shards caused by
+	// changing the data shards from oldData to newData.
+	// Unchanged data shards can be nil. No input is modified.
+	// The new parity is the old parity XOR the delta of the same index.
+	ParityDelta(oldData, newData [][]byte) ([][]byte, error)
+}
+
+// ParityEncoder is implemented by encoders returned by New
+// that can calculate a subset of the parity shards.
+// Use a type assertion on an Encoder to access it.
+type ParityEncoder interface {
+	// EncodeParity calculates a subset of the parity shards.
+	// 'rows' contains the indexes of the parity shards to calculate,
+	// counting from 0, and the result of each is written to 'parity'
+	// at the same index.
+	//
+	// 'data' must contain DataShards entries. Data shards that are nil
+	// are treated as zero, so the result is the partial parity of the
+	// present data shards. Partial parity of disjoint subsets of the data
+	// shards can be combined with CombineParity.
+	EncodeParity(data [][]byte, rows []int, parity [][]byte) error
+}
+
+// BatchEncoder is implemented by encoders returned by New
+// that can encode many stripes at once.
+// Use a type assertion on an Encoder to access it.
+type BatchEncoder interface {
+	// EncodeBatch encodes parity for several stripes, like calling Encode for each.
+	// Different stripes may have different shard sizes.
+	// Stripes are encoded in parallel, with each stripe encoded by a
+	// single goroutine, which is well suited for many small stripes.
+	// If a stripe is invalid, the error is returned and no stripes are encoded.
+	EncodeBatch(stripes [][][]byte) error
+}
+
+// VarLenEncoder is implemented by encoders returned by New
+// that support data shards of variable length.
+// Use a type assertion on an Encoder to access it.
+type VarLenEncoder interface {
+	// EncodeVarLen encodes parity like Encode, but data shards may be
+	// shorter than the parity shards, and are treated as if they were
+	// extended with zeros to the length of the parity shards.
+	// Data shards can be nil, which is the same as all zeros.
+	EncodeVarLen(shards [][]byte) error
+
+	// VerifyVarLen returns true if the parity shards contain the right data
+	// for data shards of variable length, encoded with EncodeVarLen.
+	VerifyVarLen(shards [][]byte) (bool, error)
+
+	// ReconstructVarLen will recreate missing shards encoded with EncodeVarLen.
+	// If 'lengths' contains the original length of every data shard,
+	// reconstructed data shards are returned with their original length.
+	// Otherwise they have the length of the parity shards.
+	ReconstructVarLen(shards [][]byte, lengths []int) error
+}
+
 const (
 	avx2CodeGenMinSize       = 64
 	avx2CodeGenMinShards     = 3
@@ -125,10 +277,12 @@ type reedSolomon struct {
 	ParityShards int // Number of parity shards, should not be modified.
 	Shards       int // Total number of shards. Calculated, and should not be modified.
 	m            matrix
+	matrixType   MatrixType
 	tree         *inversionTree
 	parity       [][]byte
 	o            options
 	mPool        sync.Pool
+	scratchPool  sync.Pool // *[]byte scratch for UpdatePreserve and ParityDelta
 }
 
 // ErrInvShardNum will be returned by New, if you attempt to create
@@ -249,147 +403,209 @@ func buildXorMatrix(dataShards, totalShards int) (matrix, error) {
 	return result, nil
 }
 
-// New creates a new encoder and initializes it to
-// the number of data shards and parity shards that
-// you want to use. You can reuse this encoder.
-// Note that the maximum number of total shards is 256.
-// If no options are supplied, default options are used.
-func New(dataShards, parityShards int, opts ...Option) (Encoder, error) {
-	r := reedSolomon{
-		DataShards:   dataShards,
-		ParityShards: parityShards,
-		Shards:       dataShards + parityShards,
-		o:            defaultOptions,
-	}
-
-	for _, opt := range opts {
-		opt(&r.o)
-	}
-	if dataShards <= 0 || parityShards < 0 {
-		return nil, ErrInvShardNum
-	}
-
-	if dataShards+parityShards > 256 {
-		return nil, ErrMaxShardNum
-	}
-
-	if parityShards == 0 {
-		return &r, nil
-	}
-
-	var err error
-	switch {
-	case r.o.fastOneParity && parityShards == 1:
-		r.m, err = buildXorMatrix(dataShards, r.Shards)
-	case r.o.useCauchy:
-		r.m, err = buildMatrixCauchy(dataShards, r.Shards)
-	case r.o.usePAR1Matrix:
-		r.m, err = buildMatrixPAR1(dataShards, r.Shards)
+// ErrInvalidMatrix is returned by New if the matrix supplied with
+// WithCustomMatrix does not have the expected dimensions, or if a full
+// generator matrix is given where the top square is not the identity matrix.
+var ErrInvalidMatrix = errors.New("custom matrix must have DataShards columns and ParityShards or DataShards+ParityShards rows, with the identity matrix on top")
+
+// ErrSingularMatrix is returned by New if the matrix supplied with
+// WithCustomMatrix contains a DataShards-row submatrix that cannot be inverted.
+// Such a matrix would make some combinations of lost shards unrecoverable.
+var ErrSingularMatrix = errors.New("custom matrix has a singular submatrix")
+
+// ErrMatrixValidationTooLarge is returned by New if a custom matrix
+// has too many submatrices to validate. See WithMatrixValidation.
+var ErrMatrixValidationTooLarge = errors.New("custom matrix has too many submatrices to validate")
+
+// maxMatrixValidations is the maximum number of submatrices
+// validateMatrix will check.
+const maxMatrixValidations = 1 << 20
+
+// buildMatrixCustom creates the encoding matrix from a user supplied matrix.
+// The custom matrix can either contain only the parity rows,
+// or a full systematic generator matrix where the top square
+// is the identity matrix.
+// Data is copied, so the caller may modify the input afterwards.
+func buildMatrixCustom(dataShards, totalShards int, custom [][]byte) (matrix, error) {
+	parityShards := totalShards - dataShards
+	switch len(custom) {
+	case parityShards:
+	case totalShards:
+		for r := 0; r < dataShards; r++ {
+			if len(custom[r]) != dataShards {
+				return nil, ErrInvalidMatrix
+			}
+			for c, v := range custom[r] {
+				if (r == c && v != 1) || (r != c && v != 0) {
+					return nil, ErrInvalidMatrix
+				}
+			}
+		}
+		custom = custom[dataShards:]
 	default:
-		r.m, err = buildMatrix(dataShards, r.Shards)
+		return nil, ErrInvalidMatrix
 	}
+
+	result, err := identityMatrix(dataShards)
 	if err != nil {
 		return nil, err
 	}
-
-	// Calculate what we want per round
-	r.o.perRound = cpuid.CPU.Cache.L2
-
-	divide := parityShards + 1
-	if avx2CodeGen && r.o.useAVX2 && (dataShards > maxAvx2Inputs || parityShards > maxAvx2Outputs) {
-		// Base on L1 cache if we have many inputs.
-		r.o.perRound = cpuid.CPU.Cache.L1D
-		divide = 0
-		if dataShards > maxAvx2Inputs {
-			divide += maxAvx2Inputs
-		} else {
-			divide += dataShards
-		}
-		if parityShards > maxAvx2Inputs {
-			divide += maxAvx2Outputs
-		} else {
-			divide += parityShards
+	for _, row := range custom {
+		if len(row) != dataShards {
+			return nil, ErrInvalidMatrix
 		}
+		result = append(result, append([]byte(nil), row...))
 	}
+	return result, nil
+}
 
-	if r.o.perRound <= 0 {
-		// Set to 128K if undetectable.
-		r.o.perRound = 128 << 10
+// validateMatrix checks that every square submatrix made from
+// dataShards rows of the systematic matrix m can be inverted.
+//
+// Since the top square of m is the identity matrix, this is the case
+// exactly when every square submatrix of the parity rows is invertible.
+// Each such submatrix corresponds to a set of lost data shards (the columns)
+// being recovered from a set of parity shards (the rows).
+//
+// There are C(dataShards+parityShards, parityShards)-1 such submatrices.
+// If there are more than maxMatrixValidations, ErrMatrixValidationTooLarge
+// is returned without checking any.
+func validateMatrix(m matrix, dataShards int) error {
+	parity := m[dataShards:]
+	if !binomialAtMost(len(m), len(parity), maxMatrixValidations+1) {
+		return ErrMatrixValidationTooLarge
+	}
+	maxSize := len(parity)
+	if maxSize > dataShards {
+		maxSize = dataShards
+	}
+	for size := 1; size <= maxSize; size++ {
+		rows := firstCombination(size)
+		for {
+			cols := firstCombination(size)
+			for {
+				sub, _ := newMatrix(size, size)
+				for i, r := range rows {
+					for j, c := range cols {
+						sub[i][j] = parity[r][c]
+					}
+				}
+				if _, err := sub.Invert(); err != nil {
+					return ErrSingularMatrix
+				}
+				if !nextCombination(cols, dataShards) {
+					break
+				}
+			}
+			if !nextCombination(rows, len(parity)) {
+				break
+			}
+		}
 	}
+	return nil
+}
 
-	if cpuid.CPU.ThreadsPerCore > 1 && r.o.maxGoroutines > cpuid.CPU.PhysicalCores {
-		// If multiple threads per core, make sure they don't contend for cache.
-		r.o.perRound /= cpuid.CPU.ThreadsPerCore
+// binomialAtMost returns whether the binomial coefficient C(n, k) is at most limit.
+func binomialAtMost(n, k, limit int) bool {
+	if k > n-k {
+		k = n - k
 	}
-
-	// 1 input + parity must fit in cache, and we add one more to be safer.
-	r.o.perRound = r.o.perRound / divide
-	// Align to 64 bytes.
-	r.o.perRound = ((r.o.perRound + 63) / 64) * 64
-
-	if r.o.minSplitSize <= 0 {
-		// Set minsplit as high as we can, but still have parity in L1.
-		cacheSize := cpuid.CPU.Cache.L1D
-		if cacheSize <= 0 {
-			cacheSize = 32 << 10
-		}
-
-		r.o.minSplitSize = cacheSize / (parityShards + 1)
-		// Min 1K
-		if r.o.minSplitSize < 1024 {
-			r.o.minSplitSize = 1024
+	c := 1
+	for i := 1; i <= k; i++ {
+		// C(n, i) = C(n, i-1) * (n-i+1) / i is always an integer.
+		c = c * (n - i + 1) / i
+		if c > limit {
+			return false
 		}
 	}
+	return true
+}
 
-	if r.o.shardSize > 0 {
-		p := runtime.GOMAXPROCS(0)
-		if p == 1 || r.o.shardSize <= r.o.minSplitSize*2 {
-			// Not worth it.
-			r.o.maxGoroutines = 1
-		} else {
-			g := r.o.shardSize / r.o.perRound
+// firstCombination returns the lexicographically first
+// combination of n indices: 0, 1, ..., n-1.
+func firstCombination(n int) []int {
+	c := make([]int, n)
+	for i := range c {
+		c[i] = i
+	}
+	return c
+}
 
-			// Overprovision by a factor of 2.
-			if g < p*2 && r.o.perRound > r.o.minSplitSize*2 {
-				g = p * 2
-				r.o.perRound /= 2
+// nextCombination advances c to the next combination of len(c)
+// increasing indices below n.
+// Returns false when c was the last combination.
+func nextCombination(c []int, n int) bool {
+	k := len(c)
+	for i := k - 1; i >= 0; i-- {
+		if c[i] < n-k+i {
+			c[i]++
+			for j := i + 1; j < k; j++ {
+				c[j] = c[j-1] + 1
 			}
-
-			// Have g be multiple of p
-			g += p - 1
-			g -= g % p
-
-			r.o.maxGoroutines = g
+			return true
 		}
 	}
+	return false
+}
 
-	// Generated AVX2 does not need data to stay in L1 cache between runs.
-	// We will be purely limited by RAM speed.
-	if r.canAVX2C(avx2CodeGenMinSize, maxAvx2Inputs, maxAvx2Outputs) && r.o.maxGoroutines > avx2CodeGenMaxGoroutines {
-		r.o.maxGoroutines = avx2CodeGenMaxGoroutines
+// New creates a new encoder and initializes it to
+// the number of data shards and parity shards that
+// you want to use. You can reuse this encoder.
+// Note that the maximum number of total shards is 256.
+// If no options are supplied, default options are used.
+func New(dataShards, parityShards int, opts ...Option) (Encoder, error) {
+	o := defaultOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if dataShards <= 0 || parityShards < 0 {
+		return nil, ErrInvShardNum
 	}
 
-	// Inverted matrices are cached in a tree keyed by the indices
-	// of the invalid rows of the data to reconstruct.
-	// The inversion root node will have the identity matrix as
-	// its inversion matrix because it implies there are no errors
-	// with the original data.
-	if r.o.inversionCache {
-		r.tree = newInversionTree(dataShards, parityShards)
+	if dataShards+parityShards > 256 {
+		return nil, ErrMaxShardNum
 	}
 
-	r.parity = make([][]byte, parityShards)
-	for i := range r.parity {
-		r.parity[i] = r.m[dataShards+i]
+	if parityShards == 0 {
+		enc, _, err := newReedSolomonWithMatrix(dataShards, parityShards, nil, opts...)
+		return enc, err
 	}
 
-	if avx2CodeGen && r.o.useAVX2 {
-		sz := r.DataShards * r.ParityShards * 2 * 32
-		r.mPool.New = func() interface{} {
-			return make([]byte, sz)
+	m, mType, err := buildEncodingMatrix(dataShards, parityShards, &o)
+	if err != nil {
+		return nil, err
+	}
+	enc, _, err := newReedSolomonWithMatrix(dataShards, parityShards, m, opts...)
+	if err != nil {
+		return nil, err
+	}
+	enc.(*reedSolomon).matrixType = mType
+	return enc, nil
+}
+
+// buildEncodingMatrix builds the encoding matrix selected by the options.
+func buildEncodingMatrix(dataShards, parityShards int, o *options) (m matrix, mType MatrixType, err error) {
+	totalShards := dataShards + parityShards
+	switch {
+	case o.customMatrix != nil:
+		mType = MatrixCustom
+		m, err = buildMatrixCustom(dataShards, totalShards, o.customMatrix)
+		if err == nil && o.validateMatrix {
+			err = validateMatrix(m, dataShards)
 		}
+	case o.fastOneParity && parityShards == 1:
+		mType = MatrixXor
+		m, err = buildXorMatrix(dataShards, totalShards)
+	case o.useCauchy:
+		mType = MatrixCauchy
+		m, err = buildMatrixCauchy(dataShards, totalShards)
+	case o.usePAR1Matrix:
+		mType = MatrixPAR1
+		m, err = buildMatrixPAR1(dataShards, totalShards)
+	default:
+		m, err = buildMatrix(dataShards, totalShards)
 	}
-	return &r, err
+	return m, mType, err
 }
 
 func newReedSolomonWithMatrix(dataShards, parityShards int, m matrix, opts ...Option) (Encoder, options, error) {
@@ -500,8 +716,12 @@ func newReedSolomonWithMatrix(dataShards, parityShards int, m matrix, opts ...Op
 	// The inversion root node will have the identity matrix as
 	// its inversion matrix because it implies there are no errors
 	// with the original data.
-	if r.o.inversionCache {
+	if r.o.inversionCache && r.o.sharedInversionCache {
+		r.tree = getSharedInversionTree(dataShards, parityShards, r.m, &r.o)
+	} else if r.o.inversionCache {
 		r.tree = newInversionTree(dataShards, parityShards)
+		r.tree.maxEntries = r.o.inversionCacheLimit
+		r.tree.eviction = r.o.inversionCacheEviction
 	}
 
 	r.parity = make([][]byte, parityShards)
@@ -589,10 +809,153 @@ func (r *reedSolomon) EncodeIdx(dataShard []byte, idx int, parity [][]byte) erro
 	return nil
 }
 
+// EncodeParity calculates a subset of the parity shards.
+// 'rows' contains the indexes of the parity shards to calculate,
+// counting from 0, and the result of each is written to 'parity'
+// at the same index.
+//
+// 'data' must contain DataShards entries. Data shards that are nil
+// are treated as zero, so the result is the partial parity of the
+// present data shards. Partial parity of disjoint subsets of the data
+// shards can be combined with CombineParity.
+func (r *reedSolomon) EncodeParity(data [][]byte, rows []int, parity [][]byte) error {
+	if len(data) != r.DataShards {
+		return ErrTooFewShards
+	}
+	if len(rows) != len(parity) || len(rows) == 0 {
+		return ErrInvalidInput
+	}
+	err := checkShards(data, true)
+	if err != nil {
+		return err
+	}
+	size := shardSize(data)
+	for _, p := range parity {
+		if len(p) != size {
+			return ErrShardSize
+		}
+	}
+
+	// Only use the columns of the present data shards.
+	inputs := make([][]byte, 0, r.DataShards)
+	cols := make([]int, 0, r.DataShards)
+	for c := range data {
+		if len(data[c]) != 0 {
+			inputs = append(inputs, data[c])
+			cols = append(cols, c)
+		}
+	}
+	matrixRows := make([][]byte, len(rows))
+	for i, row := range rows {
+		if row < 0 || row >= r.ParityShards {
+			return ErrInvShardNum
+		}
+		if len(cols) == r.DataShards {
+			matrixRows[i] = r.parity[row]
+			continue
+		}
+		matrixRows[i] = make([]byte, len(cols))
+		for j, c := range cols {
+			matrixRows[i][j] = r.parity[row][c]
+		}
+	}
+	r.codeSomeShards(matrixRows, inputs, parity, size)
+	return nil
+}
+
+// CombineParity adds partial parity calculated with EncodeParity
+// from disjoint subsets of data shards to dst.
+// To get the complete parity, dst should start out zeroed,
+// or be the partial parity of one of the subsets.
+// All slices must be the same size.
+func CombineParity(dst []byte, partial ...[]byte) error {
+	for _, p := range partial {
+		if len(p) != len(dst) {
+			return ErrShardSize
+		}
+	}
+	for _, p := range partial {
+		sliceXor(p, dst, &defaultOptions)
+	}
+	return nil
+}
+
 // ErrInvalidInput is returned if invalid input parameter of Update.
 var ErrInvalidInput = errors.New("invalid input")
 
 func (r *reedSolomon) Update(shards [][]byte, newDatashards [][]byte) error {
+	err := r.checkUpdate(shards, newDatashards)
+	if err != nil {
+		return err
+	}
+
+	shardSize := shardSize(shards)
+
+	// Get the slice of output buffers.
+	output := shards[r.DataShards:]
+
+	// Do the coding.
+	r.updateParityShards(r.parity, shards[0:r.DataShards], newDatashards[0:r.DataShards], output, r.ParityShards, shardSize, nil)
+	return nil
+}
+
+// UpdatePreserve updates parity like Update, but leaves both the old
+// and the new data shards unchanged.
+// The difference between old and new data is calculated in a scratch
+// buffer, which is reused between calls.
+func (r *reedSolomon) UpdatePreserve(shards [][]byte, newDatashards [][]byte) error {
+	err := r.checkUpdate(shards, newDatashards)
+	if err != nil {
+		return err
+	}
+
+	shardSize := shardSize(shards)
+	scratch := r.getScratch(shardSize)
+	defer r.scratchPool.Put(scratch)
+	r.updateParityShards(r.parity, shards[0:r.DataShards], newDatashards[0:r.DataShards], shards[r.DataShards:], r.ParityShards, shardSize, *scratch)
+	return nil
+}
+
+// ParityDelta returns the changes to the parity shards caused by
+// changing the data shards from oldData to newData.
+// Unchanged data shards can be nil in both oldData and newData.
+// Neither oldData nor newData is modified.
+//
+// The parity after the change is the old parity XOR the returned delta
+// of the same index, so the deltas can be sent to where the parity
+// shards are stored, instead of the data.
+func (r *reedSolomon) ParityDelta(oldData, newData [][]byte) ([][]byte, error) {
+	if len(oldData) != r.DataShards || len(newData) != r.DataShards {
+		return nil, ErrTooFewShards
+	}
+	size := shardSize(newData)
+	if size == 0 {
+		return nil, ErrShardNoData
+	}
+	for i := range newData {
+		if newData[i] == nil {
+			continue
+		}
+		if len(newData[i]) != size || len(oldData[i]) != size {
+			if oldData[i] == nil {
+				return nil, ErrInvalidInput
+			}
+			return nil, ErrShardSize
+		}
+	}
+
+	delta := make([][]byte, r.ParityShards)
+	for i := range delta {
+		delta[i] = r.o.allocZero(size)
+	}
+	scratch := r.getScratch(size)
+	defer r.scratchPool.Put(scratch)
+	r.updateParityShards(r.parity, oldData, newData, delta, r.ParityShards, size, *scratch)
+	return delta, nil
+}
+
+// checkUpdate checks the arguments of Update and UpdatePreserve.
+func (r *reedSolomon) checkUpdate(shards [][]byte, newDatashards [][]byte) error {
 	if len(shards) != r.Shards {
 		return ErrTooFewShards
 	}
@@ -621,24 +984,31 @@ func (r *reedSolomon) Update(shards [][]byte, newDatashards [][]byte) error {
 			return ErrInvalidInput
 		}
 	}
-
-	shardSize := shardSize(shards)
-
-	// Get the slice of output buffers.
-	output := shards[r.DataShards:]
-
-	// Do the coding.
-	r.updateParityShards(r.parity, shards[0:r.DataShards], newDatashards[0:r.DataShards], output, r.ParityShards, shardSize)
 	return nil
 }
 
-func (r *reedSolomon) updateParityShards(matrixRows, oldinputs, newinputs, outputs [][]byte, outputCount, byteCount int) {
+// getScratch returns a buffer of n bytes from the scratch pool.
+// A pointer is returned, so it can be put back without allocating.
+// The content is undefined.
+func (r *reedSolomon) getScratch(n int) *[]byte {
+	if b, ok := r.scratchPool.Get().(*[]byte); ok && cap(*b) >= n {
+		*b = (*b)[:n]
+		return b
+	}
+	b := make([]byte, n)
+	return &b
+}
+
+// updateParityShards adds the difference between old and new inputs to the outputs.
+// If scratch is nil, the difference is stored in oldinputs,
+// otherwise scratch is used and must be at least byteCount bytes.
+func (r *reedSolomon) updateParityShards(matrixRows, oldinputs, newinputs, outputs [][]byte, outputCount, byteCount int, scratch []byte) {
 	if len(outputs) == 0 {
 		return
 	}
 
 	if r.o.maxGoroutines > 1 && byteCount > r.o.minSplitSize {
-		r.updateParityShardsP(matrixRows, oldinputs, newinputs, outputs, outputCount, byteCount)
+		r.updateParityShardsP(matrixRows, oldinputs, newinputs, outputs, outputCount, byteCount, scratch)
 		return
 	}
 
@@ -648,15 +1018,22 @@ func (r *reedSolomon) updateParityShards(matrixRows, oldinputs, newinputs, outpu
 			continue
 		}
 		oldin := oldinputs[c]
-		// oldinputs data will be changed
+		if scratch != nil {
+			oldin = scratch[:len(oldin)]
+			copy(oldin, oldinputs[c])
+		}
+		// oldin data will be changed
 		sliceXor(in, oldin, &r.o)
+		if r.o.skipZeroBlocks && isZero(oldin) {
+			continue
+		}
 		for iRow := 0; iRow < outputCount; iRow++ {
 			galMulSliceXor(matrixRows[iRow][c], oldin, outputs[iRow], &r.o)
 		}
 	}
 }
 
-func (r *reedSolomon) updateParityShardsP(matrixRows, oldinputs, newinputs, outputs [][]byte, outputCount, byteCount int) {
+func (r *reedSolomon) updateParityShardsP(matrixRows, oldinputs, newinputs, outputs [][]byte, outputCount, byteCount int, scratch []byte) {
 	var wg sync.WaitGroup
 	do := byteCount / r.o.maxGoroutines
 	if do < r.o.minSplitSize {
@@ -674,11 +1051,19 @@ func (r *reedSolomon) updateParityShardsP(matrixRows, oldinputs, newinputs, outp
 				if in == nil {
 					continue
 				}
-				oldin := oldinputs[c]
-				// oldinputs data will be change
-				sliceXor(in[start:stop], oldin[start:stop], &r.o)
+				oldin := oldinputs[c][start:stop]
+				if scratch != nil {
+					// Each goroutine uses its own part of scratch.
+					oldin = scratch[start:stop]
+					copy(oldin, oldinputs[c][start:stop])
+				}
+				// oldin data will be changed
+				sliceXor(in[start:stop], oldin, &r.o)
+				if r.o.skipZeroBlocks && isZero(oldin) {
+					continue
+				}
 				for iRow := 0; iRow < outputCount; iRow++ {
-					galMulSliceXor(matrixRows[iRow][c], oldin[start:stop], outputs[iRow][start:stop], &r.o)
+					galMulSliceXor(matrixRows[iRow][c], oldin, outputs[iRow][start:stop], &r.o)
 				}
 			}
 			wg.Done()
@@ -725,6 +1110,16 @@ func (r *reedSolomon) codeSomeShards(matrixRows, inputs, outputs [][]byte, byteC
 	if len(outputs) == 0 {
 		return
 	}
+	if r.o.skipZeroBlocks {
+		r.codeSomeShardsSparse(matrixRows, inputs, outputs, byteCount, true)
+		return
+	}
+	r.codeSomeShardsDense(matrixRows, inputs, outputs, byteCount)
+}
+
+// codeSomeShardsDense performs the same operation as codeSomeShards,
+// using all inputs.
+func (r *reedSolomon) codeSomeShardsDense(matrixRows, inputs, outputs [][]byte, byteCount int) {
 	switch {
 	case r.o.useAVX512 && r.o.maxGoroutines > 1 && byteCount > r.o.minSplitSize && len(inputs) >= 4 && len(outputs) >= 2:
 		r.codeSomeShardsAvx512P(matrixRows, inputs, outputs, byteCount)
@@ -736,6 +1131,16 @@ func (r *reedSolomon) codeSomeShards(matrixRows, inputs, outputs [][]byte, byteC
 		r.codeSomeShardsP(matrixRows, inputs, outputs, byteCount)
 		return
 	}
+	r.codeSomeShardsSerial(matrixRows, inputs, outputs, byteCount)
+}
+
+// codeSomeShardsSerial performs the same operation as codeSomeShards,
+// but without using goroutines.
+func (r *reedSolomon) codeSomeShardsSerial(matrixRows, inputs, outputs [][]byte, byteCount int) {
+	if r.o.useAVX512 && len(inputs) >= 4 && len(outputs) >= 2 {
+		r.codeSomeShardsAvx512(matrixRows, inputs, outputs, byteCount)
+		return
+	}
 
 	// Process using no goroutines
 	start, end := 0, r.o.perRound
@@ -1039,7 +1444,8 @@ func (r *reedSolomon) checkSomeShards(matrixRows, inputs, toCheck [][]byte, byte
 
 	outputs := make([][]byte, len(toCheck))
 	for i := range outputs {
-		outputs[i] = make([]byte, byteCount)
+		outputs[i] = r.o.alloc(byteCount)
+		defer r.o.free(outputs[i])
 	}
 	r.codeSomeShards(matrixRows, inputs, outputs, byteCount)
 
@@ -1127,6 +1533,83 @@ func (r *reedSolomon) ReconstructData(shards [][]byte) error {
 	return r.reconstruct(shards, true)
 }
 
+// ErrInvalidRange is returned if a range is outside the shards.
+var ErrInvalidRange = errors.New("range is outside the shards")
+
+// EncodeRange will update parity like Encode, but only for the
+// 'length' bytes starting at 'offset' in every shard.
+// Parity outside the range is not modified.
+// If the range is outside the shards, ErrInvalidRange is returned.
+func (r *reedSolomon) EncodeRange(shards [][]byte, offset, length int) error {
+	if len(shards) != r.Shards {
+		return ErrTooFewShards
+	}
+	err := checkShards(shards, false)
+	if err != nil {
+		return err
+	}
+	if offset < 0 || length <= 0 || offset+length > len(shards[0]) || offset+length < offset {
+		return ErrInvalidRange
+	}
+
+	window := make([][]byte, r.Shards)
+	for i := range shards {
+		window[i] = shards[i][offset : offset+length]
+	}
+	r.codeSomeShards(r.parity, window[:r.DataShards], window[r.DataShards:], length)
+	return nil
+}
+
+// ReconstructRange will recreate the missing shards like Reconstruct,
+// but only the 'length' bytes starting at 'offset'.
+//
+// You indicate that a shard is missing by setting it to nil or zero-length.
+// If a missing shard has capacity for the full shard size,
+// it is resliced to the full size and only the range is overwritten,
+// so other parts of the shard are kept.
+// Otherwise a new zero filled []byte will be allocated.
+//
+// If the range is outside the shards, ErrInvalidRange is returned.
+func (r *reedSolomon) ReconstructRange(shards [][]byte, offset, length int) error {
+	if len(shards) != r.Shards {
+		return ErrTooFewShards
+	}
+	err := checkShards(shards, true)
+	if err != nil {
+		return err
+	}
+	size := shardSize(shards)
+	if offset < 0 || length <= 0 || offset+length > size || offset+length < offset {
+		return ErrInvalidRange
+	}
+
+	// Missing shards get a zero length window with capacity for the range,
+	// so the range is reconstructed in place.
+	window := make([][]byte, r.Shards)
+	for i := range shards {
+		if len(shards[i]) == 0 {
+			if cap(shards[i]) >= size {
+				shards[i] = shards[i][:size]
+			} else {
+				shards[i] = r.o.allocZero(size)
+			}
+			window[i] = shards[i][offset:offset]
+			continue
+		}
+		window[i] = shards[i][offset : offset+length]
+	}
+	err = r.reconstruct(window, false)
+	if err != nil {
+		// Restore missing shards.
+		for i := range window {
+			if len(window[i]) == 0 {
+				shards[i] = shards[i][:0]
+			}
+		}
+	}
+	return err
+}
+
 // reconstruct will recreate the missing data shards, and unless
 // dataOnly is true, also the missing parity shards
 //
@@ -1191,40 +1674,9 @@ func (r *reedSolomon) reconstruct(shards [][]byte, dataOnly bool) error {
 		}
 	}
 
-	// Attempt to get the cached inverted matrix out of the tree
-	// based on the indices of the invalid rows.
-	dataDecodeMatrix := r.tree.GetInvertedMatrix(invalidIndices)
-
-	// If the inverted matrix isn't cached in the tree yet we must
-	// construct it ourselves and insert it into the tree for the
-	// future.  In this way the inversion tree is lazily loaded.
-	if dataDecodeMatrix == nil {
-		// Pull out the rows of the matrix that correspond to the
-		// shards that we have and build a square matrix.  This
-		// matrix could be used to generate the shards that we have
-		// from the original data.
-		subMatrix, _ := newMatrix(r.DataShards, r.DataShards)
-		for subMatrixRow, validIndex := range validIndices {
-			for c := 0; c < r.DataShards; c++ {
-				subMatrix[subMatrixRow][c] = r.m[validIndex][c]
-			}
-		}
-		// Invert the matrix, so we can go from the encoded shards
-		// back to the original data.  Then pull out the row that
-		// generates the shard that we want to decode.  Note that
-		// since this matrix maps back to the original data, it can
-		// be used to create a data shard, but not a parity shard.
-		dataDecodeMatrix, err = subMatrix.Invert()
-		if err != nil {
-			return err
-		}
-
-		// Cache the inverted matrix in the tree for future use keyed on the
-		// indices of the invalid rows.
-		err = r.tree.InsertInvertedMatrix(invalidIndices, dataDecodeMatrix, r.Shards)
-		if err != nil {
-			return err
-		}
+	dataDecodeMatrix, err := r.getDataDecodeMatrix(validIndices, invalidIndices)
+	if err != nil {
+		return err
 	}
 
 	// Re-create any data shards that were missing.
@@ -1241,7 +1693,7 @@ func (r *reedSolomon) reconstruct(shards [][]byte, dataOnly bool) error {
 			if cap(shards[iShard]) >= shardSize {
 				shards[iShard] = shards[iShard][0:shardSize]
 			} else {
-				shards[iShard] = make([]byte, shardSize)
+				shards[iShard] = r.o.alloc(shardSize)
 			}
 			outputs[outputCount] = shards[iShard]
 			matrixRows[outputCount] = dataDecodeMatrix[iShard]
@@ -1267,7 +1719,7 @@ func (r *reedSolomon) reconstruct(shards [][]byte, dataOnly bool) error {
 			if cap(shards[iShard]) >= shardSize {
 				shards[iShard] = shards[iShard][0:shardSize]
 			} else {
-				shards[iShard] = make([]byte, shardSize)
+				shards[iShard] = r.o.alloc(shardSize)
 			}
 			outputs[outputCount] = shards[iShard]
 			matrixRows[outputCount] = r.parity[iShard-r.DataShards]
@@ -1278,6 +1730,55 @@ func (r *reedSolomon) reconstruct(shards [][]byte, dataOnly bool) error {
 	return nil
 }
 
+// InversionCacheStats returns statistics of the inversion cache.
+// If the cache is disabled, all values are zero.
+func (r *reedSolomon) InversionCacheStats() InversionCacheStats {
+	return r.tree.Stats()
+}
+
+// getDataDecodeMatrix returns the matrix that recreates the data shards
+// from the shards at validIndices, when the shards at invalidIndices are missing.
+func (r *reedSolomon) getDataDecodeMatrix(validIndices, invalidIndices []int) (matrix, error) {
+	// Attempt to get the cached inverted matrix out of the tree
+	// based on the indices of the invalid rows.
+	dataDecodeMatrix := r.tree.GetInvertedMatrix(invalidIndices)
+	if dataDecodeMatrix != nil {
+		return dataDecodeMatrix, nil
+	}
+
+	// If the inverted matrix isn't cached in the tree yet we must
+	// construct it ourselves and insert it into the tree for the
+	// future.  In this way the inversion tree is lazily loaded.
+	//
+	// Pull out the rows of the matrix that correspond to the
+	// shards that we have and build a square matrix.  This
+	// matrix could be used to generate the shards that we have
+	// from the original data.
+	subMatrix, _ := newMatrix(r.DataShards, r.DataShards)
+	for subMatrixRow, validIndex := range validIndices {
+		for c := 0; c < r.DataShards; c++ {
+			subMatrix[subMatrixRow][c] = r.m[validIndex][c]
+		}
+	}
+	// Invert the matrix, so we can go from the encoded shards
+	// back to the original data.  Then pull out the row that
+	// generates the shard that we want to decode.  Note that
+	// since this matrix maps back to the original data, it can
+	// be used to create a data shard, but not a parity shard.
+	dataDecodeMatrix, err := subMatrix.Invert()
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the inverted matrix in the tree for future use keyed on the
+	// indices of the invalid rows.
+	err = r.tree.InsertInvertedMatrix(invalidIndices, dataDecodeMatrix, r.Shards)
+	if err != nil {
+		return nil, err
+	}
+	return dataDecodeMatrix, nil
+}
+
 // ErrShortData will be returned by Split(), if there isn't enough data
 // to fill the number of shards.
 var ErrShortData = errors.New("not enough data to fill the number of requested shards")
@@ -1294,6 +1795,13 @@ var ErrShortData = errors.New("not enough data to fill the number of requested s
 //
 // The data will not be copied, except for the last shard, so you
 // should not modify the data of the input slice afterwards.
+//
+// If an Allocator is set with WithAllocator, every shard that doesn't fit
+// within the capacity of data is allocated separately from the allocator
+// instead of sharing one padding buffer.
+// These shards are owned by the caller, who may return them with Allocator.Free.
+// With AllocAligned all shards will be aligned if data is allocated
+// by the same allocator and the shard size is a multiple of the alignment.
 func (r *reedSolomon) Split(data []byte) ([][]byte, error) {
 	if len(data) == 0 {
 		return nil, ErrShortData
@@ -1306,6 +1814,10 @@ func (r *reedSolomon) Split(data []byte) ([][]byte, error) {
 		data = data[:cap(data)]
 	}
 
+	if r.o.allocator != nil {
+		return r.splitAlloc(data, dataLen, perShard), nil
+	}
+
 	// Only allocate memory if necessary
 	var padding []byte
 	if len(data) < (r.Shards * perShard) {
@@ -1336,6 +1848,37 @@ func (r *reedSolomon) Split(data []byte) ([][]byte, error) {
 	return dst, nil
 }
 
+// splitAlloc splits data like Split, but shards that don't fit
+// within the capacity of data are allocated separately with the allocator.
+// 'data' must be extended to its capacity, with dataLen bytes of data.
+func (r *reedSolomon) splitAlloc(data []byte, dataLen, perShard int) [][]byte {
+	dst := make([][]byte, r.Shards)
+	for i := range dst {
+		if len(data) >= perShard {
+			dst[i] = data[:perShard:perShard]
+			data = data[perShard:]
+		} else {
+			dst[i] = r.o.alloc(perShard)
+			n := copy(dst[i], data)
+			data = data[n:]
+			for j := n; j < perShard; j++ {
+				dst[i][j] = 0
+			}
+		}
+		// Clear bytes after the data.
+		if start := i * perShard; start+perShard > dataLen && i < r.DataShards {
+			from := dataLen - start
+			if from < 0 {
+				from = 0
+			}
+			for j := from; j < perShard; j++ {
+				dst[i][j] = 0
+			}
+		}
+	}
+	return dst
+}
+
 // ErrReconstructRequired is returned if too few data shards are intact and a
 // reconstruction is required before you can successfully join the shards.
 var ErrReconstructRequired = errors.New("reconstruction required as one or more required data shards are nil")
@@ -144,6 +144,7 @@ func testOpts() [][]Option {
 		{WithMaxGoroutines(1), WithMinSplitSize(500000), withSSSE3(false), withAVX2(false), withAVX512(false)},
 		{WithAutoGoroutines(50000), WithMinSplitSize(500)},
 		{WithInversionCache(false)},
+		{WithSkipZeroBlocks(true)},
 	}
 	for _, o := range opts[:] {
 		if defaultOptions.useSSSE3 {
@@ -506,6 +507,159 @@ func testUpdate(t *testing.T, o ...Option) {
 	}
 }
 
+func TestUpdatePreserve(t *testing.T) {
+	const data, parity = 10, 3
+	for _, perShard := range []int{10, 1000, 50000} {
+		// Use goroutines for the largest size.
+		r, err := New(data, parity, testOptions(WithMinSplitSize(1000))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards := make([][]byte, data+parity)
+		for s := range shards {
+			shards[s] = make([]byte, perShard)
+			fillRandom(shards[s])
+		}
+		if err := r.Encode(shards); err != nil {
+			t.Fatal(err)
+		}
+		oldParity := make([][]byte, parity)
+		for i := range oldParity {
+			oldParity[i] = append([]byte(nil), shards[data+i]...)
+		}
+		oldData := make([][]byte, data)
+		for i := range oldData {
+			oldData[i] = append([]byte(nil), shards[i]...)
+		}
+
+		newData := make([][]byte, data)
+		for _, s := range []int{1, 4, 9} {
+			newData[s] = make([]byte, perShard)
+			fillRandom(newData[s])
+		}
+		newCopy := make([][]byte, data)
+		for i := range newData {
+			if newData[i] != nil {
+				newCopy[i] = append([]byte(nil), newData[i]...)
+			}
+		}
+
+		delta, err := r.(PreservingUpdater).ParityDelta(shards[:data], newData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.(PreservingUpdater).UpdatePreserve(shards, newData); err != nil {
+			t.Fatal(err)
+		}
+		for i := range oldData {
+			if !bytes.Equal(shards[i], oldData[i]) || !bytes.Equal(newData[i], newCopy[i]) {
+				t.Fatalf("data shard %d was modified", i)
+			}
+		}
+		for i := range newData {
+			if newData[i] != nil {
+				shards[i] = newData[i]
+			}
+		}
+		ok, err := r.Verify(shards)
+		if err != nil || !ok {
+			t.Fatal("verification failed", err)
+		}
+		for i := range delta {
+			for j := range delta[i] {
+				oldParity[i][j] ^= delta[i][j]
+			}
+			if !bytes.Equal(oldParity[i], shards[data+i]) {
+				t.Errorf("parity delta %d mismatch", i)
+			}
+		}
+	}
+
+	r, err := New(data, parity, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldData := make([][]byte, data)
+	newData := make([][]byte, data)
+	newData[2] = make([]byte, 10)
+	if _, err := r.(PreservingUpdater).ParityDelta(oldData, newData); err != ErrInvalidInput {
+		t.Errorf("expected %v, got %v", ErrInvalidInput, err)
+	}
+	oldData[2] = make([]byte, 11)
+	if _, err := r.(PreservingUpdater).ParityDelta(oldData, newData); err != ErrShardSize {
+		t.Errorf("expected %v, got %v", ErrShardSize, err)
+	}
+	if _, err := r.(PreservingUpdater).ParityDelta(oldData, make([][]byte, data)); err != ErrShardNoData {
+		t.Errorf("expected %v, got %v", ErrShardNoData, err)
+	}
+}
+
+func TestEncodeParity(t *testing.T) {
+	const data, parity, perShard = 10, 4, 20000
+	for _, opts := range testOpts() {
+		r, err := New(data, parity, testOptions(opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards := make([][]byte, data+parity)
+		for s := range shards {
+			shards[s] = make([]byte, perShard)
+			if s < data {
+				fillRandom(shards[s])
+			}
+		}
+		if err := r.Encode(shards); err != nil {
+			t.Fatal(err)
+		}
+
+		// Single and multiple rows from all data.
+		rows := []int{2, 0}
+		out := [][]byte{make([]byte, perShard), make([]byte, perShard)}
+		if err := r.(ParityEncoder).EncodeParity(shards[:data], rows, out); err != nil {
+			t.Fatal(err)
+		}
+		for i, row := range rows {
+			if !bytes.Equal(out[i], shards[data+row]) {
+				t.Errorf("parity row %d mismatch", row)
+			}
+		}
+
+		// Partial parity of disjoint subsets.
+		for row := 0; row < parity; row++ {
+			var partials [][]byte
+			for _, subset := range [][2]int{{0, 3}, {3, 4}, {4, data}} {
+				in := make([][]byte, data)
+				copy(in[subset[0]:subset[1]], shards[subset[0]:subset[1]])
+				partial := make([]byte, perShard)
+				if err := r.(ParityEncoder).EncodeParity(in, []int{row}, [][]byte{partial}); err != nil {
+					t.Fatal(err)
+				}
+				partials = append(partials, partial)
+			}
+			combined := make([]byte, perShard)
+			if err := CombineParity(combined, partials...); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(combined, shards[data+row]) {
+				t.Errorf("combined parity row %d mismatch", row)
+			}
+		}
+
+		if err := r.(ParityEncoder).EncodeParity(shards[:data], []int{parity}, out[:1]); err != ErrInvShardNum {
+			t.Errorf("expected %v, got %v", ErrInvShardNum, err)
+		}
+		if err := r.(ParityEncoder).EncodeParity(shards[:data], []int{0}, [][]byte{make([]byte, 10)}); err != ErrShardSize {
+			t.Errorf("expected %v, got %v", ErrShardSize, err)
+		}
+		if err := r.(ParityEncoder).EncodeParity(make([][]byte, data), []int{0}, out[:1]); err != ErrShardNoData {
+			t.Errorf("expected %v, got %v", ErrShardNoData, err)
+		}
+		if err := CombineParity(out[0], make([]byte, 10)); err != ErrShardSize {
+			t.Errorf("expected %v, got %v", ErrShardSize, err)
+		}
+	}
+}
+
 func TestReconstruct(t *testing.T) {
 	testReconstruct(t)
 	for i, o := range testOpts() {
@@ -1591,6 +1745,150 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestCustomMatrix(t *testing.T) {
+	const dataShards, parityShards = 10, 4
+	std, err := buildMatrix(dataShards, dataShards+parityShards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, custom := range [][][]byte{std[dataShards:], std} {
+		enc, err := New(dataShards, parityShards, testOptions(WithCustomMatrix(custom))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards := make([][]byte, dataShards+parityShards)
+		want := make([][]byte, dataShards+parityShards)
+		for i := range shards {
+			shards[i] = make([]byte, 1000)
+			want[i] = shards[i]
+			if i < dataShards {
+				fillRandom(shards[i])
+			} else {
+				want[i] = make([]byte, 1000)
+			}
+		}
+		if err = enc.Encode(shards); err != nil {
+			t.Fatal(err)
+		}
+		if err = ref.Encode(want); err != nil {
+			t.Fatal(err)
+		}
+		for i := range shards {
+			if !bytes.Equal(shards[i], want[i]) {
+				t.Fatal("shard", i, "differs from standard matrix output")
+			}
+		}
+		shards[0], shards[11] = nil, nil
+		if err = enc.Reconstruct(shards); err != nil {
+			t.Fatal(err)
+		}
+		ok, err := enc.Verify(shards)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatal("verification failed")
+		}
+	}
+
+	// Two identical parity rows cannot recover two lost shards.
+	bad := [][]byte{{1, 2, 3}, {1, 2, 3}}
+	_, err = New(3, 2, WithCustomMatrix(bad))
+	if err != ErrSingularMatrix {
+		t.Errorf("expected %v, got %v", ErrSingularMatrix, err)
+	}
+	_, err = New(3, 2, WithCustomMatrix(bad), WithMatrixValidation(false))
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Wide matrices are refused instead of checking millions of submatrices.
+	wide := make([][]byte, 10)
+	for i := range wide {
+		wide[i] = make([]byte, 20)
+		for j := range wide[i] {
+			wide[i][j] = byte(i + j + 1)
+		}
+	}
+	_, err = New(20, 10, WithCustomMatrix(wide))
+	if err != ErrMatrixValidationTooLarge {
+		t.Errorf("expected %v, got %v", ErrMatrixValidationTooLarge, err)
+	}
+	_, err = New(20, 10, WithCustomMatrix(wide), WithMatrixValidation(false))
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if !binomialAtMost(24, 8, maxMatrixValidations) || binomialAtMost(30, 10, maxMatrixValidations) {
+		t.Error("unexpected validation bound")
+	}
+
+	// A zero coefficient means that data shard cannot be recovered from that parity.
+	_, err = New(3, 1, WithCustomMatrix([][]byte{{1, 0, 1}}))
+	if err != ErrSingularMatrix {
+		t.Errorf("expected %v, got %v", ErrSingularMatrix, err)
+	}
+
+	for _, bad := range [][][]byte{
+		{{1, 2, 3}},
+		{{1, 2}, {3, 4}},
+		{{1, 0, 0}, {0, 1, 0}, {0, 0, 2}, {1, 1, 1}, {1, 2, 3}},
+		{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {1, 1, 1}, {1, 2}},
+	} {
+		_, err = New(3, 2, WithCustomMatrix(bad))
+		if err != ErrInvalidMatrix {
+			t.Errorf("%v: expected %v, got %v", bad, ErrInvalidMatrix, err)
+		}
+	}
+}
+
+func TestInversionCacheLimit(t *testing.T) {
+	const dataShards, parityShards = 8, 4
+	enc, err := New(dataShards, parityShards, testOptions(WithInversionCacheLimit(3))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := randomBytes(dataShards+parityShards, 100)
+	if err = enc.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		for missing := 0; missing < dataShards; missing++ {
+			shards[missing] = nil
+			if err = enc.ReconstructData(shards); err != nil {
+				t.Fatal(err)
+			}
+			stats := enc.(InversionCacheEncoder).InversionCacheStats()
+			if stats.Entries > 3 {
+				t.Fatalf("%d entries cached, limit is 3", stats.Entries)
+			}
+		}
+	}
+	stats := enc.(InversionCacheEncoder).InversionCacheStats()
+	if stats.Misses != 2*dataShards || stats.Hits != 0 || stats.Evictions != 2*dataShards-3 || stats.Bytes <= 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	ok, err := enc.Verify(shards)
+	if err != nil || !ok {
+		t.Fatal("verification failed", err)
+	}
+
+	enc, err = New(dataShards, parityShards, testOptions(WithInversionCache(false))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards[0] = nil
+	if err = enc.ReconstructData(shards); err != nil {
+		t.Fatal(err)
+	}
+	if stats := enc.(InversionCacheEncoder).InversionCacheStats(); stats != (InversionCacheStats{}) {
+		t.Fatalf("expected empty stats, got %+v", stats)
+	}
+}
+
 // Benchmark 10 data shards and 4 parity shards and 160MB data.
 func BenchmarkSplit10x4x160M(b *testing.B) {
 	benchmarkSplit(b, 10, 4, 160*1024*1024)
@@ -1691,3 +1989,107 @@ func BenchmarkParallel_8x8x32M(b *testing.B)   { benchmarkParallel(b, 8, 8, 32<<
 func BenchmarkParallel_8x3x1M(b *testing.B) { benchmarkParallel(b, 8, 3, 1<<20) }
 func BenchmarkParallel_8x4x1M(b *testing.B) { benchmarkParallel(b, 8, 4, 1<<20) }
 func BenchmarkParallel_8x5x1M(b *testing.B) { benchmarkParallel(b, 8, 5, 1<<20) }
+
+func TestEncodeRange(t *testing.T) {
+	const dataShards, parityShards, size = 5, 3, 1000
+	enc, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := make([][]byte, dataShards+parityShards)
+	for i := range shards {
+		shards[i] = make([]byte, size)
+		if i < dataShards {
+			fillRandom(shards[i])
+		}
+	}
+	if err := enc.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	want := make([][]byte, len(shards))
+	for i := range shards {
+		want[i] = append([]byte(nil), shards[i]...)
+	}
+
+	// Rewrite a range of data.
+	const offset, length = 100, 300
+	for i := 0; i < dataShards; i++ {
+		fillRandom(shards[i][offset : offset+length])
+		copy(want[i], shards[i])
+	}
+	if err := enc.Encode(want); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.(RangeEncoder).EncodeRange(shards, offset, length); err != nil {
+		t.Fatal(err)
+	}
+	for i := range shards {
+		if !bytes.Equal(shards[i], want[i]) {
+			t.Fatalf("shard %d mismatch", i)
+		}
+	}
+
+	for _, r := range [][2]int{{-1, 10}, {0, 0}, {size - 10, 11}, {size, 1}} {
+		if err := enc.(RangeEncoder).EncodeRange(shards, r[0], r[1]); err != ErrInvalidRange {
+			t.Errorf("range %v: expected %v, got %v", r, ErrInvalidRange, err)
+		}
+	}
+	if err := enc.(RangeEncoder).EncodeRange(shards[:2], 0, 1); err != ErrTooFewShards {
+		t.Errorf("expected %v, got %v", ErrTooFewShards, err)
+	}
+}
+
+func TestReconstructRange(t *testing.T) {
+	const dataShards, parityShards, size = 5, 3, 1000
+	enc, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := make([][]byte, dataShards+parityShards)
+	for i := range shards {
+		shards[i] = make([]byte, size)
+		if i < dataShards {
+			fillRandom(shards[i])
+		}
+	}
+	if err := enc.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	want := make([][]byte, len(shards))
+	for i := range shards {
+		want[i] = append([]byte(nil), shards[i]...)
+	}
+
+	// Lose a range in a data and a parity shard, keeping the rest.
+	const offset, length = 200, 50
+	for _, i := range []int{1, 6} {
+		for j := offset; j < offset+length; j++ {
+			shards[i][j] = 0
+		}
+		shards[i] = shards[i][:0]
+	}
+	// Lose a shard completely.
+	shards[3] = nil
+	if err := enc.(RangeEncoder).ReconstructRange(shards, offset, length); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{1, 6} {
+		if !bytes.Equal(shards[i], want[i]) {
+			t.Errorf("shard %d mismatch", i)
+		}
+	}
+	if len(shards[3]) != size || !bytes.Equal(shards[3][offset:offset+length], want[3][offset:offset+length]) {
+		t.Error("shard 3 range mismatch")
+	}
+	if shards[3][offset-1] != 0 || shards[3][offset+length] != 0 {
+		t.Error("shard 3 written outside range")
+	}
+
+	shards[0], shards[1], shards[2], shards[3] = nil, nil, nil, nil
+	if err := enc.(RangeEncoder).ReconstructRange(shards, offset, length); err != ErrTooFewShards {
+		t.Errorf("expected %v, got %v", ErrTooFewShards, err)
+	}
+	if err := enc.(RangeEncoder).ReconstructRange(want, size-1, 2); err != ErrInvalidRange {
+		t.Errorf("expected %v, got %v", ErrInvalidRange, err)
+	}
+}
@@ -0,0 +1,128 @@
+package reedsolomon
+
+import (
+	"errors"
+)
+
+// ShardFingerprintSize is the size of a shard fingerprint in bytes.
+const ShardFingerprintSize = 16
+
+// ShardFingerprint is a keyed hash of a shard that is linear over GF(2^8).
+//
+// The shard is divided into blocks of 4KB. For each fingerprint byte, the blocks
+// are multiplied by pseudo-random coefficients derived from the key and the block
+// number and added together, and the bytes of the resulting block are multiplied by
+// pseudo-random coefficients derived from the key and the byte position and added.
+// Since encoding is also linear, the fingerprint of a parity shard can be
+// calculated from the fingerprints of the data shards, without the data.
+//
+// For two different shards, the probability that the fingerprints are
+// equal is at most 2^-112, as long as the key is not known when the shards are chosen.
+// Trailing zeros do not affect the fingerprint.
+// Fingerprints can be compared with ==.
+type ShardFingerprint [ShardFingerprintSize]byte
+
+// fingerprintBlockSize is the size of the blocks of a shard that are
+// added together when calculating a fingerprint.
+const fingerprintBlockSize = 4096
+
+// fingerprintPositionKey separates the coefficients of byte positions
+// from the coefficients of blocks.
+const fingerprintPositionKey = 0x6a09e667f3bcc909
+
+// ErrFingerprintCount is returned if the number of fingerprints doesn't match
+// the number of coefficients or data shards.
+var ErrFingerprintCount = errors.New("number of fingerprints does not match")
+
+// NewShardFingerprint returns the fingerprint of shard using the given key.
+// The same key must be used for all shards that are compared or combined.
+func NewShardFingerprint(key uint64, shard []byte) ShardFingerprint {
+	var fp ShardFingerprint
+	size := len(shard)
+	if size > fingerprintBlockSize {
+		size = fingerprintBlockSize
+	}
+	if size == 0 {
+		return fp
+	}
+
+	// Add the blocks multiplied by coefficients for each fingerprint byte.
+	var acc [ShardFingerprintSize][]byte
+	buf := make([]byte, ShardFingerprintSize*size)
+	for i := range acc {
+		acc[i] = buf[i*size : (i+1)*size]
+	}
+	var coeffs [ShardFingerprintSize]byte
+	for block := uint64(0); len(shard) > 0; block++ {
+		in := shard
+		if len(in) > fingerprintBlockSize {
+			in = in[:fingerprintBlockSize]
+		}
+		fingerprintBytes(key, block, &coeffs)
+		for i := range acc {
+			galMulSliceXor(coeffs[i], in, acc[i][:len(in)], &defaultOptions)
+		}
+		shard = shard[len(in):]
+	}
+
+	// Add the bytes of the sums multiplied by coefficients for each position.
+	for pos := 0; pos < size; pos++ {
+		fingerprintBytes(key^fingerprintPositionKey, uint64(pos), &coeffs)
+		for i := range fp {
+			fp[i] ^= mulTable[coeffs[i]][acc[i][pos]]
+		}
+	}
+	return fp
+}
+
+// fingerprintBytes fills dst with pseudo-random coefficients for counter n.
+func fingerprintBytes(key, n uint64, dst *[ShardFingerprintSize]byte) {
+	a := fingerprintCoefficients(key, 2*n)
+	b := fingerprintCoefficients(key, 2*n+1)
+	for i := 0; i < 8; i++ {
+		dst[i] = byte(a >> (8 * i))
+		dst[8+i] = byte(b >> (8 * i))
+	}
+}
+
+// fingerprintCoefficients returns 8 pseudo-random coefficients for counter n.
+// This is the SplitMix64 generator, seeded with the key.
+func fingerprintCoefficients(key, n uint64) uint64 {
+	z := key + (n+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// CombineShardFingerprints returns the fingerprint of the shard that is the sum of
+// the shards with the given fingerprints, each multiplied by the corresponding coefficient.
+//
+// Using a row of the encoding matrix as coefficients and the fingerprints of
+// the data shards gives the fingerprint of the parity shard for that row.
+func CombineShardFingerprints(coefficients []byte, fps []ShardFingerprint) (ShardFingerprint, error) {
+	var res ShardFingerprint
+	if len(coefficients) != len(fps) {
+		return res, ErrFingerprintCount
+	}
+	for i, c := range coefficients {
+		mt := &mulTable[c]
+		for j, v := range fps[i] {
+			res[j] ^= mt[v]
+		}
+	}
+	return res, nil
+}
+
+// ParityFingerprints returns the expected fingerprints of the parity shards,
+// given the fingerprints of the data shards.
+// The fingerprints must be created with the same key.
+func (r *reedSolomon) ParityFingerprints(data []ShardFingerprint) ([]ShardFingerprint, error) {
+	if len(data) != r.DataShards {
+		return nil, ErrFingerprintCount
+	}
+	res := make([]ShardFingerprint, r.ParityShards)
+	for i, row := range r.parity {
+		res[i], _ = CombineShardFingerprints(row, data)
+	}
+	return res, nil
+}
@@ -0,0 +1,82 @@
+package reedsolomon
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardFingerprint(t *testing.T) {
+	for i, o := range [][]Option{nil, {WithCauchyMatrix()}, {WithPAR1Matrix()}} {
+		t.Run(fmt.Sprintf("opt-%d", i), func(t *testing.T) {
+			testShardFingerprint(t, o...)
+		})
+	}
+}
+
+func testShardFingerprint(t *testing.T, o ...Option) {
+	const dataShards, parityShards = 10, 4
+	const key = 0x1234567890abcdef
+	r, err := New(dataShards, parityShards, testOptions(o...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{1, 7, 8, 9, 1000, 4096, 4097, 100003} {
+		shards := randomBytes(dataShards+parityShards, size)
+		if err := r.Encode(shards); err != nil {
+			t.Fatal(err)
+		}
+		data := make([]ShardFingerprint, dataShards)
+		for i := range data {
+			data[i] = NewShardFingerprint(key, shards[i])
+		}
+		parity, err := r.(FingerprintEncoder).ParityFingerprints(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range parity {
+			got := NewShardFingerprint(key, shards[dataShards+i])
+			if got != want {
+				t.Fatalf("size %d: parity %d fingerprint mismatch", size, i)
+			}
+			// A single changed byte must be detected.
+			shards[dataShards+i][size/2] ^= 1
+			if NewShardFingerprint(key, shards[dataShards+i]) == want {
+				t.Fatalf("size %d: parity %d corruption not detected", size, i)
+			}
+			shards[dataShards+i][size/2] ^= 1
+		}
+		// Trailing zeros do not change the fingerprint.
+		padded := append(append([]byte(nil), shards[0]...), make([]byte, 13)...)
+		if NewShardFingerprint(key, padded) != data[0] {
+			t.Fatalf("size %d: trailing zeros changed fingerprint", size)
+		}
+		if NewShardFingerprint(key+1, shards[0]) == data[0] {
+			t.Fatalf("size %d: key does not affect fingerprint", size)
+		}
+	}
+
+	if _, err := r.(FingerprintEncoder).ParityFingerprints(make([]ShardFingerprint, dataShards-1)); err != ErrFingerprintCount {
+		t.Errorf("expected %v, got %v", ErrFingerprintCount, err)
+	}
+	if _, err := CombineShardFingerprints([]byte{1, 2}, make([]ShardFingerprint, 1)); err != ErrFingerprintCount {
+		t.Errorf("expected %v, got %v", ErrFingerprintCount, err)
+	}
+}
+
+func benchmarkShardFingerprint(b *testing.B, size int) {
+	shard := make([]byte, size)
+	fillRandom(shard)
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewShardFingerprint(0x1234567890abcdef, shard)
+	}
+}
+
+func BenchmarkShardFingerprint10K(b *testing.B) {
+	benchmarkShardFingerprint(b, 10000)
+}
+
+func BenchmarkShardFingerprint1M(b *testing.B) {
+	benchmarkShardFingerprint(b, 1<<20)
+}
@@ -0,0 +1,207 @@
+package reedsolomon
+
+import (
+	"bytes"
+)
+
+// zeroBlockSize is the size of the blocks checked for zeros
+// when skipping zero blocks.
+const zeroBlockSize = 4096
+
+// zeroBlock is used for comparing with zeros.
+var zeroBlock [zeroBlockSize]byte
+
+// isZero returns whether all bytes of b are zero.
+func isZero(b []byte) bool {
+	for len(b) > 0 {
+		n := len(b)
+		if n > len(zeroBlock) {
+			n = len(zeroBlock)
+		}
+		if !bytes.Equal(b[:n], zeroBlock[:n]) {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
+
+// minSparseRun is the number of bytes below which a run of blocks
+// is merged with the following blocks when skipping zero blocks,
+// if only a few more inputs must be coded for the merged run.
+const minSparseRun = 8 * zeroBlockSize
+
+// codeSomeShardsSparse performs the same operation as codeSomeShards,
+// but inputs may be shorter than byteCount and are treated as if
+// they were extended with zeros. If checkZero is set, blocks of inputs that are zero
+// are also skipped.
+//
+// The inputs are checked in blocks of zeroBlockSize bytes.
+// Consecutive blocks with the same non-zero inputs are coded together
+// by codeSomeShardsDense, with the other inputs and their matrix columns left out.
+// Runs shorter than minSparseRun are merged with the following blocks,
+// coding the inputs that are non-zero in any of them, if that
+// adds at most a quarter of the inputs.
+// Blocks where an input ends are coded separately, with a zero padded copy of the input.
+// Outputs of blocks where all inputs are zero are set to zero.
+func (r *reedSolomon) codeSomeShardsSparse(matrixRows, inputs, outputs [][]byte, byteCount int, checkZero bool) {
+	s := newSparseCoder(matrixRows, inputs, outputs, r.codeSomeShardsDense)
+	s.codeAll(byteCount, checkZero)
+}
+
+// sparseCoder codes runs of blocks with some inputs left out.
+// The slices used for each run are reused between runs.
+type sparseCoder struct {
+	matrixRows, inputs, outputs [][]byte
+
+	// code codes a run with the selected inputs.
+	code func(matrixRows, inputs, outputs [][]byte, byteCount int)
+
+	in, out, rows [][]byte
+	rowBuf        []byte
+	pad           [][]byte
+}
+
+func newSparseCoder(matrixRows, inputs, outputs [][]byte, code func(matrixRows, inputs, outputs [][]byte, byteCount int)) *sparseCoder {
+	return &sparseCoder{
+		matrixRows: matrixRows,
+		inputs:     inputs,
+		outputs:    outputs,
+		code:       code,
+		in:         make([][]byte, 0, len(inputs)),
+		out:        make([][]byte, len(outputs)),
+		rows:       make([][]byte, len(outputs)),
+		pad:        make([][]byte, len(inputs)),
+	}
+}
+
+// codeAll codes the first byteCount bytes of the outputs.
+func (s *sparseCoder) codeAll(byteCount int, checkZero bool) {
+	var nonZero, runNonZero, merged []int
+	runStart := 0
+	for start := 0; start < byteCount; start += zeroBlockSize {
+		end := start + zeroBlockSize
+		if end > byteCount {
+			end = byteCount
+		}
+		nonZero = nonZero[:0]
+		partial := false
+		for i, input := range s.inputs {
+			n := len(input)
+			if n <= start {
+				continue
+			}
+			if n < end {
+				partial = true
+			} else {
+				n = end
+			}
+			if checkZero && isZero(input[start:n]) {
+				continue
+			}
+			nonZero = append(nonZero, i)
+		}
+		if partial {
+			s.codeNonZero(runNonZero, runStart, start)
+			s.codeNonZero(nonZero, start, end)
+			runStart = end
+			continue
+		}
+		if start > runStart && !equalInts(nonZero, runNonZero) {
+			merged = unionInts(merged[:0], runNonZero, nonZero)
+			// Inputs that would be coded without being needed.
+			extra := 2*len(merged) - len(runNonZero) - len(nonZero)
+			if start-runStart < minSparseRun && extra*4 <= len(s.inputs) {
+				runNonZero, merged = merged, runNonZero
+				continue
+			}
+			s.codeNonZero(runNonZero, runStart, start)
+			runStart = start
+		}
+		runNonZero = append(runNonZero[:0], nonZero...)
+	}
+	s.codeNonZero(runNonZero, runStart, byteCount)
+}
+
+// codeNonZero codes bytes start to end of the outputs
+// from the inputs with the indexes in nonZero.
+// Inputs that end before 'end' are copied and extended with zeros,
+// which is only done for single blocks.
+func (s *sparseCoder) codeNonZero(nonZero []int, start, end int) {
+	if start >= end {
+		return
+	}
+	if len(nonZero) == 0 {
+		for i := range s.outputs {
+			out := s.outputs[i][start:end]
+			for j := range out {
+				out[j] = 0
+			}
+		}
+		return
+	}
+	in := s.in[:0]
+	for _, idx := range nonZero {
+		input := s.inputs[idx]
+		if len(input) < end {
+			if s.pad[idx] == nil {
+				s.pad[idx] = make([]byte, zeroBlockSize)
+			}
+			pad := s.pad[idx][:end-start]
+			n := copy(pad, input[start:])
+			for j := range pad[n:] {
+				pad[n+j] = 0
+			}
+			in = append(in, pad)
+			continue
+		}
+		in = append(in, input[start:end])
+	}
+	for i := range s.outputs {
+		s.out[i] = s.outputs[i][start:end]
+	}
+	rows := s.matrixRows
+	if len(nonZero) != len(s.inputs) {
+		if s.rowBuf == nil {
+			s.rowBuf = make([]byte, len(s.outputs)*len(s.inputs))
+		}
+		for i := range s.rows {
+			row := s.rowBuf[i*len(s.inputs) : i*len(s.inputs)+len(nonZero)]
+			for j, idx := range nonZero {
+				row[j] = s.matrixRows[i][idx]
+			}
+			s.rows[i] = row
+		}
+		rows = s.rows
+	}
+	s.code(rows, in, s.out, end-start)
+}
+
+// unionInts appends the sorted union of the sorted slices a and b to dst.
+func unionInts(dst, a, b []int) []int {
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			dst, a = append(dst, a[0]), a[1:]
+		case a[0] > b[0]:
+			dst, b = append(dst, b[0]), b[1:]
+		default:
+			dst, a, b = append(dst, a[0]), a[1:], b[1:]
+		}
+	}
+	dst = append(dst, a...)
+	return append(dst, b...)
+}
+
+// equalInts returns whether a and b contain the same values.
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package reedsolomon
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSkipZeroBlocks(t *testing.T) {
+	const dataShards, parityShards = 10, 4
+	for _, size := range []int{1, 100, 4096, 10000, 100000} {
+		for _, opts := range append(testOpts(), []Option{WithMaxGoroutines(1)}) {
+			ref, err := New(dataShards, parityShards, testOptions(opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			enc, err := New(dataShards, parityShards, testOptions(append(opts, WithSkipZeroBlocks(true))...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			shards := make([][]byte, dataShards+parityShards)
+			for i := range shards {
+				shards[i] = make([]byte, size)
+			}
+			// Some shards are zero, some have zero blocks.
+			for i := 0; i < dataShards; i++ {
+				switch i % 3 {
+				case 0:
+				case 1:
+					fillRandom(shards[i])
+				case 2:
+					for j := 0; j < size; j += 2 * zeroBlockSize {
+						end := j + zeroBlockSize
+						if end > size {
+							end = size
+						}
+						fillRandom(shards[i][j:end])
+					}
+				}
+			}
+			want := make([][]byte, len(shards))
+			for i := range shards {
+				want[i] = append([]byte(nil), shards[i]...)
+			}
+			if err := ref.Encode(want); err != nil {
+				t.Fatal(err)
+			}
+			// Parity must be overwritten, also where data is zero.
+			for i := dataShards; i < len(shards); i++ {
+				fillRandom(shards[i])
+			}
+			if err := enc.Encode(shards); err != nil {
+				t.Fatal(err)
+			}
+			for i := range shards {
+				if !bytes.Equal(shards[i], want[i]) {
+					t.Fatalf("size %d: shard %d mismatch", size, i)
+				}
+			}
+
+			// All zero.
+			zero := make([][]byte, dataShards+parityShards)
+			for i := range zero {
+				zero[i] = make([]byte, size)
+			}
+			fillRandom(zero[dataShards])
+			if err := enc.Encode(zero); err != nil {
+				t.Fatal(err)
+			}
+			if !isZero(zero[dataShards]) {
+				t.Fatal("parity of zero data is not zero")
+			}
+
+			// Update with unchanged shards.
+			newData := make([][]byte, dataShards)
+			newData[1] = append([]byte(nil), shards[1]...)
+			newData[2] = make([]byte, size)
+			fillRandom(newData[2])
+			if err := enc.Update(shards, newData); err != nil {
+				t.Fatal(err)
+			}
+			// Update modifies the old data shards.
+			shards[1], shards[2] = newData[1], newData[2]
+			ok, err := ref.Verify(shards)
+			if err != nil || !ok {
+				t.Fatal("verification after update failed", err)
+			}
+
+			// Reconstruct.
+			shards[1], shards[dataShards+1] = nil, nil
+			if err := enc.Reconstruct(shards); err != nil {
+				t.Fatal(err)
+			}
+			ok, err = ref.Verify(shards)
+			if err != nil || !ok {
+				t.Fatal("verification after reconstruct failed", err)
+			}
+		}
+	}
+
+	// Stream encoding of a sparse stream.
+	const size = 50000
+	data := make([][]byte, dataShards)
+	for i := range data {
+		data[i] = make([]byte, size)
+		if i%2 == 1 {
+			fillRandom(data[i][size/2:])
+		}
+	}
+	r, err := NewStream(dataShards, parityShards, testOptions(WithSkipZeroBlocks(true), WithStreamBlockSize(10000))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parity := emptyBuffers(parityShards)
+	if err := r.Encode(toReaders(toBuffers(data)), toWriters(parity)); err != nil {
+		t.Fatal(err)
+	}
+	shards := append(data, make([][]byte, parityShards)...)
+	for i := range parity {
+		shards[dataShards+i] = parity[i].Bytes()
+	}
+	enc, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := enc.Verify(shards)
+	if err != nil || !ok {
+		t.Fatal("stream verification failed", err)
+	}
+}
+
+func benchmarkEncodeSkipZeroAlternating(b *testing.B, zeroShards int) {
+	const dataShards, parityShards, size = 10, 4, 1 << 20
+	enc, err := New(dataShards, parityShards, testOptions(WithSkipZeroBlocks(true))...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	shards := make([][]byte, dataShards+parityShards)
+	for i := range shards {
+		shards[i] = make([]byte, size)
+		if i < dataShards {
+			fillRandom(shards[i])
+		}
+	}
+	// Zero every other block of the first zeroShards data shards,
+	// alternating between neighbouring shards.
+	for i := 0; i < zeroShards; i++ {
+		for start := (i % 2) * zeroBlockSize; start < size; start += 2 * zeroBlockSize {
+			block := shards[i][start : start+zeroBlockSize]
+			for j := range block {
+				block[j] = 0
+			}
+		}
+	}
+	b.SetBytes(int64(size * dataShards))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := enc.Encode(shards); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeSkipZeroAlternating1(b *testing.B) {
+	benchmarkEncodeSkipZeroAlternating(b, 1)
+}
+
+func BenchmarkEncodeSkipZeroAlternating10(b *testing.B) {
+	benchmarkEncodeSkipZeroAlternating(b, 10)
+}
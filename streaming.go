@@ -9,10 +9,12 @@ package reedsolomon
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"time"
 )
 
 // StreamEncoder is an interface to encode Reed-Salomon parity sets for your data.
@@ -91,6 +93,127 @@ type StreamEncoder interface {
 	Join(dst io.Writer, shards []io.Reader, outSize int64) error
 }
 
+// StreamContextEncoder is implemented by stream encoders returned by NewStream
+// with versions of the StreamEncoder methods that can be canceled.
+// Use a type assertion on a StreamEncoder to access it.
+type StreamContextEncoder interface {
+	// EncodeContext functions as Encode, but stops when ctx is done.
+	// Cancellation is checked between blocks and while waiting for
+	// concurrent reads and writes. If ctx is done, ctx.Err() is returned.
+	// If concurrent reads or writes are enabled, reads and writes in
+	// progress when ctx is done are not waited for, so the streams
+	// may still be read or written after EncodeContext returns.
+	EncodeContext(ctx context.Context, data []io.Reader, parity []io.Writer) error
+
+	// VerifyContext functions as Verify, but stops when ctx is done.
+	// If ctx is done, false and ctx.Err() is returned.
+	// If concurrent reads are enabled, reads in progress when ctx is done
+	// are not waited for, so the streams may still be read after VerifyContext returns.
+	VerifyContext(ctx context.Context, shards []io.Reader) (bool, error)
+
+	// ReconstructContext functions as Reconstruct, but stops when ctx is done.
+	// If ctx is done, ctx.Err() is returned.
+	// If concurrent reads or writes are enabled, reads and writes in
+	// progress when ctx is done are not waited for, so the streams
+	// may still be read or written after ReconstructContext returns.
+	ReconstructContext(ctx context.Context, valid []io.Reader, fill []io.Writer) error
+
+	// SplitContext functions as Split, but stops when ctx is done.
+	// If ctx is done, ctx.Err() is returned.
+	// The streams are not used after SplitContext returns.
+	SplitContext(ctx context.Context, data io.Reader, dst []io.Writer, size int64) error
+
+	// JoinContext functions as Join, but stops when ctx is done.
+	// If ctx is done, ctx.Err() is returned.
+	// The streams are not used after JoinContext returns.
+	JoinContext(ctx context.Context, dst io.Writer, shards []io.Reader, outSize int64) error
+}
+
+// StreamHedgedEncoder is implemented by stream encoders returned by NewStream
+// that can reconstruct from the first responding shards.
+// Use a type assertion on a StreamEncoder to access it.
+type StreamHedgedEncoder interface {
+	// ReconstructHedged will reconstruct shards from the first responding shards.
+	//
+	// 'shards' should contain a reader for every available shard, with nil
+	// for shards known to be missing. Each block is decoded from the first
+	// DataShards readers that deliver it, so a slow reader does not stall the stream.
+	//
+	// The complete content of every shard with a non-nil writer in 'fill' is written.
+	//
+	// Readers that return an error, or that haven't delivered a block within
+	// timeout (if > 0) and were not needed to decode it, are treated as missing
+	// for the rest of the stream. Needed readers are waited for.
+	// If too few readers remain, ErrTooFewShards is returned.
+	// If ctx is done, ctx.Err() is returned.
+	// Reads in progress when ReconstructHedged returns are not waited for,
+	// so the readers may still be read after it returns. The same applies to
+	// writes if concurrent writes are enabled.
+	ReconstructHedged(ctx context.Context, shards []io.Reader, fill []io.Writer, timeout time.Duration) error
+}
+
+// StreamBlockVerifier is implemented by stream encoders returned by NewStream
+// that can report the location of mismatching blocks.
+// Use a type assertion on a StreamEncoder to access it.
+type StreamBlockVerifier interface {
+	// VerifyBlocks checks the parity of every block and reports the
+	// location of every block where the parity doesn't match the data.
+	//
+	// The number of shards must match the number total data+parity shards
+	// given to NewStream(), and each reader must supply the same number of bytes.
+	//
+	// If 'all' is false, verification stops at the first mismatching block.
+	// If ctx is done, ctx.Err() is returned.
+	// If concurrent reads are enabled, reads in progress when ctx is done
+	// are not waited for, so the streams may still be read after VerifyBlocks returns.
+	VerifyBlocks(ctx context.Context, shards []io.Reader, all bool) (*StreamVerifyResult, error)
+}
+
+// StreamResumableEncoder is implemented by stream encoders returned by NewStream
+// that can encode with checkpoints and resume from them.
+// Use a type assertion on a StreamEncoder to access it.
+type StreamResumableEncoder interface {
+	// EncodeResumable encodes parity like Encode, but starting at offset 'start'
+	// of the streams, calling 'checkpoint' every time a block of parity has been written.
+	// Readers and writers implementing io.Seeker are seeked to start,
+	// others must already be positioned there.
+	// Resuming from any checkpoint gives output identical to an uninterrupted run.
+	EncodeResumable(ctx context.Context, data []io.Reader, parity []io.Writer, start int64, checkpoint func(StreamCheckpoint) error) error
+}
+
+// StreamReaderAtEncoder is implemented by stream encoders returned by NewStream
+// with random access to shards and data through io.ReaderAt and io.WriterAt.
+// Use a type assertion on a StreamEncoder to access it.
+type StreamReaderAtEncoder interface {
+	// JoinReader returns a reader with random access to the data that was split into the shards.
+	//
+	// 'shards' must contain an io.ReaderAt for every shard, with nil for missing shards,
+	// and size must be the size of the original data.
+	// If a data shard is missing or fails to read, only the blocks
+	// overlapping the requested range are reconstructed.
+	JoinReader(shards []io.ReaderAt, size int64) (*io.SectionReader, error)
+
+	// EncodeRange will update parity like Encode, but only for the
+	// 'length' bytes starting at 'offset' in every shard.
+	// Parity outside the range is not modified.
+	EncodeRange(data []io.ReaderAt, parity []io.WriterAt, offset, length int64) error
+
+	// ReconstructRange will recreate missing shards like Reconstruct,
+	// but only for the 'length' bytes starting at 'offset'.
+	// The reconstructed range is written to the fill writers at the same offsets.
+	ReconstructRange(valid []io.ReaderAt, fill []io.WriterAt, offset, length int64) error
+
+	// SplitAt splits the input into data shards like Split, but reads each shard
+	// at its offset in 'data', so the shards are read and written concurrently.
+	// The number of concurrent shards can be set with WithStreamParallelism.
+	SplitAt(ctx context.Context, data io.ReaderAt, dst []io.Writer, size int64) error
+
+	// JoinAt joins the data shards like Join, but writes each shard
+	// at its offset in 'dst', so the shards are read and written concurrently.
+	// The number of concurrent shards can be set with WithStreamParallelism.
+	JoinAt(ctx context.Context, dst io.WriterAt, shards []io.Reader, outSize int64) error
+}
+
 // StreamReadError is returned when a read error is encountered
 // that relates to a supplied stream.
 // This will allow you to find out which reader has failed.
@@ -135,9 +258,9 @@ type rsStream struct {
 	o options
 
 	// Shard reader
-	readShards func(dst [][]byte, in []io.Reader) error
+	readShards func(ctx context.Context, dst [][]byte, in []io.Reader) error
 	// Shard writer
-	writeShards func(out []io.Writer, in [][]byte) error
+	writeShards func(ctx context.Context, out []io.Writer, in [][]byte) error
 
 	blockPool sync.Pool
 }
@@ -195,7 +318,17 @@ func NewStreamC(dataShards, parityShards int, conReads, conWrites bool, o ...Opt
 	return NewStream(dataShards, parityShards, append(o, WithConcurrentStreamReads(conReads), WithConcurrentStreamWrites(conWrites))...)
 }
 
+// createSlice returns block buffers for all shards.
+// If an allocator is set, the buffers are allocated from it,
+// otherwise they are taken from the pool.
 func (r *rsStream) createSlice() [][]byte {
+	if r.o.allocator != nil {
+		out := make([][]byte, r.r.Shards)
+		for i := range out {
+			out[i] = r.o.allocator.Alloc(r.o.streamBS)
+		}
+		return out
+	}
 	out := r.blockPool.Get().([][]byte)
 	for i := range out {
 		out[i] = out[i][:r.o.streamBS]
@@ -203,6 +336,26 @@ func (r *rsStream) createSlice() [][]byte {
 	return out
 }
 
+// putSlice returns buffers from createSlice to the allocator or the pool.
+func (r *rsStream) putSlice(all [][]byte) {
+	if r.o.allocator != nil {
+		for _, b := range all {
+			r.o.free(b)
+		}
+		return
+	}
+	r.blockPool.Put(all)
+}
+
+// releaseSlice returns buffers from createSlice like putSlice.
+// If ctx is done, concurrent readers or writers may still be using the
+// buffers, so they are left for the garbage collector instead.
+func (r *rsStream) releaseSlice(ctx context.Context, all [][]byte) {
+	if ctx.Err() == nil {
+		r.putSlice(all)
+	}
+}
+
 // Encodes parity shards for a set of data shards.
 //
 // Input is 'shards' containing readers for data shards followed by parity shards
@@ -219,6 +372,16 @@ func (r *rsStream) createSlice() [][]byte {
 // will be returned. If a parity writer returns an error, a
 // StreamWriteError will be returned.
 func (r *rsStream) Encode(data []io.Reader, parity []io.Writer) error {
+	return r.EncodeContext(context.Background(), data, parity)
+}
+
+// EncodeContext functions as Encode, but stops when ctx is done.
+// Cancellation is checked between blocks and while waiting for
+// concurrent reads and writes. If ctx is done, ctx.Err() is returned.
+// If concurrent reads or writes are enabled, reads and writes in
+// progress when ctx is done are not waited for, so the streams
+// may still be read or written after EncodeContext returns.
+func (r *rsStream) EncodeContext(ctx context.Context, data []io.Reader, parity []io.Writer) error {
 	if len(data) != r.r.DataShards {
 		return ErrTooFewShards
 	}
@@ -226,19 +389,111 @@ func (r *rsStream) Encode(data []io.Reader, parity []io.Writer) error {
 	if len(parity) != r.r.ParityShards {
 		return ErrTooFewShards
 	}
+	return r.encode(ctx, data, parity, 0, nil)
+}
+
+// StreamCheckpoint describes the progress of EncodeResumable.
+type StreamCheckpoint struct {
+	// Offset is the number of bytes that have been read from every data
+	// stream and written to every parity stream, including the start offset.
+	// Encoding can be resumed from this offset.
+	Offset int64
+
+	// Blocks is the number of blocks processed since encoding was started or resumed.
+	Blocks int
+}
+
+// EncodeResumable encodes parity like Encode, but starting at offset 'start'
+// of the streams, calling 'checkpoint' every time a block of parity has been written.
+//
+// If start is > 0, every reader and writer implementing io.Seeker is
+// seeked to start. Other readers and writers must already be positioned at start.
+// Since the parity of each byte only depends on the data at the same offset,
+// resuming from the offset of any checkpoint gives output identical to an
+// uninterrupted run.
+//
+// Writers should have flushed their data before a checkpoint is stored.
+// If checkpoint returns an error, encoding is stopped and the error is returned.
+// If start is > 0 and there is no more data, nil is returned.
+func (r *rsStream) EncodeResumable(ctx context.Context, data []io.Reader, parity []io.Writer, start int64, checkpoint func(StreamCheckpoint) error) error {
+	if len(data) != r.r.DataShards {
+		return ErrTooFewShards
+	}
+	if len(parity) != r.r.ParityShards {
+		return ErrTooFewShards
+	}
+	if start < 0 {
+		return ErrInvalidRange
+	}
+	if start > 0 {
+		for i := range data {
+			if s, ok := data[i].(io.Seeker); ok {
+				if _, err := s.Seek(start, io.SeekStart); err != nil {
+					return StreamReadError{Err: err, Stream: i}
+				}
+			}
+		}
+		for i := range parity {
+			if s, ok := parity[i].(io.Seeker); ok {
+				if _, err := s.Seek(start, io.SeekStart); err != nil {
+					return StreamWriteError{Err: err, Stream: i}
+				}
+			}
+		}
+	}
+	return r.encode(ctx, data, parity, start, checkpoint)
+}
+
+// encode parity from data starting at offset start,
+// and call checkpoint, if not nil, after every block has been written.
+func (r *rsStream) encode(ctx context.Context, data []io.Reader, parity []io.Writer, start int64, checkpoint func(StreamCheckpoint) error) error {
+	cp := StreamCheckpoint{Offset: start}
+	written := func(size int) error {
+		cp.Offset += int64(size)
+		cp.Blocks++
+		if checkpoint == nil {
+			return nil
+		}
+		return checkpoint(cp)
+	}
+
+	if r.o.pipeline > 1 {
+		err := r.pipeline(ctx,
+			func(ctx context.Context, all [][]byte) error {
+				return r.readShards(ctx, all[:r.r.DataShards], data)
+			},
+			func(all [][]byte) error {
+				trimShards(all[r.r.DataShards:], shardSize(all[:r.r.DataShards]))
+				return r.r.Encode(all)
+			},
+			func(ctx context.Context, all [][]byte) error {
+				out := all[r.r.DataShards:]
+				if err := r.writeShards(ctx, parity, out); err != nil {
+					return err
+				}
+				return written(shardSize(out))
+			})
+		if err == ErrShardNoData && start > 0 {
+			return nil
+		}
+		return err
+	}
 
 	all := r.createSlice()
-	defer r.blockPool.Put(all)
+	defer r.releaseSlice(ctx, all)
 	in := all[:r.r.DataShards]
 	out := all[r.r.DataShards:]
 	read := 0
 
 	for {
-		err := r.readShards(in, data)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := r.readShards(ctx, in, data)
 		switch err {
 		case nil:
 		case io.EOF:
-			if read == 0 {
+			if read == 0 && start == 0 {
 				return ErrShardNoData
 			}
 			return nil
@@ -251,7 +506,11 @@ func (r *rsStream) Encode(data []io.Reader, parity []io.Writer) error {
 		if err != nil {
 			return err
 		}
-		err = r.writeShards(parity, out)
+		err = r.writeShards(ctx, parity, out)
+		if err != nil {
+			return err
+		}
+		err = written(shardSize(in))
 		if err != nil {
 			return err
 		}
@@ -271,12 +530,15 @@ func trimShards(in [][]byte, size int) [][]byte {
 	return in
 }
 
-func readShards(dst [][]byte, in []io.Reader) error {
+func readShards(ctx context.Context, dst [][]byte, in []io.Reader) error {
 	if len(in) != len(dst) {
 		panic("internal error: in and dst size do not match")
 	}
 	size := -1
 	for i := range in {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if in[i] == nil {
 			dst[i] = dst[i][:0]
 			continue
@@ -306,11 +568,14 @@ func readShards(dst [][]byte, in []io.Reader) error {
 	return nil
 }
 
-func writeShards(out []io.Writer, in [][]byte) error {
+func writeShards(ctx context.Context, out []io.Writer, in [][]byte) error {
 	if len(out) != len(in) {
 		panic("internal error: in and out size do not match")
 	}
 	for i := range in {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if out[i] == nil {
 			continue
 		}
@@ -332,34 +597,40 @@ type readResult struct {
 	err  error
 }
 
-// cReadShards reads shards concurrently
-func cReadShards(dst [][]byte, in []io.Reader) error {
+// cReadShards reads shards concurrently.
+// If ctx is done before all reads have completed, ctx.Err() is returned
+// and the remaining reads are abandoned. They may still write to dst.
+func cReadShards(ctx context.Context, dst [][]byte, in []io.Reader) error {
 	if len(in) != len(dst) {
 		panic("internal error: in and dst size do not match")
 	}
-	var wg sync.WaitGroup
-	wg.Add(len(in))
 	res := make(chan readResult, len(in))
+	pending := 0
 	for i := range in {
 		if in[i] == nil {
 			dst[i] = dst[i][:0]
-			wg.Done()
 			continue
 		}
+		pending++
 		go func(i int) {
-			defer wg.Done()
 			n, err := io.ReadFull(in[i], dst[i])
 			// The error is EOF only if no bytes were read.
 			// If an EOF happens after reading some but not all the bytes,
 			// ReadFull returns ErrUnexpectedEOF.
 			res <- readResult{size: n, err: err, n: i}
-
 		}(i)
 	}
-	wg.Wait()
-	close(res)
+	results := make([]readResult, 0, pending)
+	for len(results) < pending {
+		select {
+		case r := <-res:
+			results = append(results, r)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 	size := -1
-	for r := range res {
+	for _, r := range results {
 		switch r.err {
 		case io.ErrUnexpectedEOF, io.EOF:
 			if size < 0 {
@@ -380,17 +651,16 @@ func cReadShards(dst [][]byte, in []io.Reader) error {
 	return nil
 }
 
-// cWriteShards writes shards concurrently
-func cWriteShards(out []io.Writer, in [][]byte) error {
+// cWriteShards writes shards concurrently.
+// If ctx is done before all writes have completed, ctx.Err() is returned
+// and the remaining writes are abandoned. They may still read from in.
+func cWriteShards(ctx context.Context, out []io.Writer, in [][]byte) error {
 	if len(out) != len(in) {
 		panic("internal error: in and out size do not match")
 	}
 	var errs = make(chan error, len(out))
-	var wg sync.WaitGroup
-	wg.Add(len(out))
 	for i := range in {
 		go func(i int) {
-			defer wg.Done()
 			if out[i] == nil {
 				errs <- nil
 				return
@@ -402,18 +672,23 @@ func cWriteShards(out []io.Writer, in [][]byte) error {
 			}
 			if n != len(in[i]) {
 				errs <- StreamWriteError{Err: io.ErrShortWrite, Stream: i}
+				return
 			}
+			errs <- nil
 		}(i)
 	}
-	wg.Wait()
-	close(errs)
-	for err := range errs {
-		if err != nil {
-			return err
+	var firstErr error
+	for range out {
+		select {
+		case err := <-errs:
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
-
-	return nil
+	return firstErr
 }
 
 // Verify returns true if the parity shards contain correct data.
@@ -425,15 +700,26 @@ func cWriteShards(out []io.Writer, in [][]byte) error {
 // If a shard stream returns an error, a StreamReadError type error
 // will be returned.
 func (r *rsStream) Verify(shards []io.Reader) (bool, error) {
+	return r.VerifyContext(context.Background(), shards)
+}
+
+// VerifyContext functions as Verify, but stops when ctx is done.
+// If ctx is done, false and ctx.Err() is returned.
+// If concurrent reads are enabled, reads in progress when ctx is done
+// are not waited for, so the streams may still be read after VerifyContext returns.
+func (r *rsStream) VerifyContext(ctx context.Context, shards []io.Reader) (bool, error) {
 	if len(shards) != r.r.Shards {
 		return false, ErrTooFewShards
 	}
 
 	read := 0
 	all := r.createSlice()
-	defer r.blockPool.Put(all)
+	defer r.releaseSlice(ctx, all)
 	for {
-		err := r.readShards(all, shards)
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		err := r.readShards(ctx, all, shards)
 		if err == io.EOF {
 			if read == 0 {
 				return false, ErrShardNoData
@@ -451,6 +737,98 @@ func (r *rsStream) Verify(shards []io.Reader) (bool, error) {
 	}
 }
 
+// StreamVerifyResult is the result of a VerifyBlocks call.
+type StreamVerifyResult struct {
+	// Size is the number of bytes verified in each shard.
+	Size int64
+
+	// Mismatches contains every block where the parity
+	// doesn't match the data, in stream order.
+	Mismatches []StreamMismatch
+}
+
+// OK returns true if no mismatches were found.
+func (s *StreamVerifyResult) OK() bool {
+	return len(s.Mismatches) == 0
+}
+
+// StreamMismatch describes a block where the parity doesn't match the data.
+type StreamMismatch struct {
+	Block  int   // The block number, counting from 0
+	Offset int64 // The offset of the block in each shard
+	Size   int   // The size of the block in each shard
+
+	// Parity contains the shard indexes of the parity shards
+	// that don't match the data. These are indexes into the shards
+	// given to VerifyBlocks, so the first parity shard is DataShards.
+	// If all parity shards mismatch, it is likely a data shard is corrupted.
+	Parity []int
+}
+
+// VerifyBlocks checks the parity of every block and reports the
+// location of every block where the parity doesn't match the data.
+//
+// The number of shards must match the number total data+parity shards
+// given to NewStream(), and each reader must supply the same number of bytes.
+// Blocks are the size of the stream block size.
+//
+// If 'all' is false, verification stops at the first mismatching block
+// and Size will include the mismatching block.
+// If a shard stream returns an error, a StreamReadError type error
+// will be returned. If ctx is done, ctx.Err() is returned.
+// If concurrent reads are enabled, reads in progress when ctx is done
+// are not waited for, so the streams may still be read after VerifyBlocks returns.
+func (r *rsStream) VerifyBlocks(ctx context.Context, shards []io.Reader, all bool) (*StreamVerifyResult, error) {
+	if len(shards) != r.r.Shards {
+		return nil, ErrTooFewShards
+	}
+
+	in := r.createSlice()
+	defer r.releaseSlice(ctx, in)
+	parity := make([][]byte, r.r.ParityShards)
+	for i := range parity {
+		parity[i] = r.o.alloc(r.o.streamBS)
+		defer r.o.free(parity[i])
+	}
+	res := &StreamVerifyResult{}
+	for block := 0; ; block++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		err := r.readShards(ctx, in, shards)
+		if err == io.EOF {
+			if res.Size == 0 {
+				return nil, ErrShardNoData
+			}
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := checkShards(in, false); err != nil {
+			return nil, err
+		}
+		size := len(in[0])
+		for i := range parity {
+			parity[i] = parity[i][:size]
+		}
+		r.r.codeSomeShards(r.r.parity, in[:r.r.DataShards], parity, size)
+		var bad []int
+		for i := range parity {
+			if !bytes.Equal(parity[i], in[r.r.DataShards+i]) {
+				bad = append(bad, r.r.DataShards+i)
+			}
+		}
+		if len(bad) > 0 {
+			res.Mismatches = append(res.Mismatches, StreamMismatch{Block: block, Offset: res.Size, Size: size, Parity: bad})
+		}
+		res.Size += int64(size)
+		if len(bad) > 0 && !all {
+			return res, nil
+		}
+	}
+}
+
 // ErrReconstructMismatch is returned by the StreamEncoder, if you supply
 // "valid" and "fill" streams on the same index.
 // Therefore it is impossible to see if you consider the shard valid
@@ -472,6 +850,15 @@ var ErrReconstructMismatch = errors.New("valid shards and fill shards are mutual
 // However its integrity is not automatically verified.
 // Use the Verify function to check in case the data set is complete.
 func (r *rsStream) Reconstruct(valid []io.Reader, fill []io.Writer) error {
+	return r.ReconstructContext(context.Background(), valid, fill)
+}
+
+// ReconstructContext functions as Reconstruct, but stops when ctx is done.
+// If ctx is done, ctx.Err() is returned.
+// If concurrent reads or writes are enabled, reads and writes in
+// progress when ctx is done are not waited for, so the streams
+// may still be read or written after ReconstructContext returns.
+func (r *rsStream) ReconstructContext(ctx context.Context, valid []io.Reader, fill []io.Writer) error {
 	if len(valid) != r.r.Shards {
 		return ErrTooFewShards
 	}
@@ -479,8 +866,6 @@ func (r *rsStream) Reconstruct(valid []io.Reader, fill []io.Writer) error {
 		return ErrTooFewShards
 	}
 
-	all := r.createSlice()
-	defer r.blockPool.Put(all)
 	reconDataOnly := true
 	for i := range valid {
 		if valid[i] != nil && fill[i] != nil {
@@ -491,9 +876,31 @@ func (r *rsStream) Reconstruct(valid []io.Reader, fill []io.Writer) error {
 		}
 	}
 
+	if r.o.pipeline > 1 {
+		return r.pipeline(ctx,
+			func(ctx context.Context, all [][]byte) error {
+				return r.readShards(ctx, all, valid)
+			},
+			func(all [][]byte) error {
+				trimShards(all, shardSize(all))
+				if reconDataOnly {
+					return r.r.ReconstructData(all)
+				}
+				return r.r.Reconstruct(all)
+			},
+			func(ctx context.Context, all [][]byte) error {
+				return r.writeShards(ctx, fill, all)
+			})
+	}
+
+	all := r.createSlice()
+	defer r.releaseSlice(ctx, all)
 	read := 0
 	for {
-		err := r.readShards(all, valid)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := r.readShards(ctx, all, valid)
 		if err == io.EOF {
 			if read == 0 {
 				return ErrShardNoData
@@ -514,7 +921,7 @@ func (r *rsStream) Reconstruct(valid []io.Reader, fill []io.Writer) error {
 		if err != nil {
 			return err
 		}
-		err = r.writeShards(fill, all)
+		err = r.writeShards(ctx, fill, all)
 		if err != nil {
 			return err
 		}
@@ -529,6 +936,13 @@ func (r *rsStream) Reconstruct(valid []io.Reader, fill []io.Writer) error {
 // If there are to few shards given, ErrTooFewShards will be returned.
 // If the total data size is less than outSize, ErrShortData will be returned.
 func (r *rsStream) Join(dst io.Writer, shards []io.Reader, outSize int64) error {
+	return r.JoinContext(context.Background(), dst, shards, outSize)
+}
+
+// JoinContext functions as Join, but stops when ctx is done.
+// If ctx is done, ctx.Err() is returned.
+// The streams are not used after JoinContext returns.
+func (r *rsStream) JoinContext(ctx context.Context, dst io.Writer, shards []io.Reader, outSize int64) error {
 	// Do we have enough shards?
 	if len(shards) < r.r.DataShards {
 		return ErrTooFewShards
@@ -542,7 +956,7 @@ func (r *rsStream) Join(dst io.Writer, shards []io.Reader, outSize int64) error
 		}
 	}
 	// Join all shards
-	src := io.MultiReader(shards...)
+	src := contextReader{ctx: ctx, r: io.MultiReader(shards...)}
 
 	// Copy data to dst
 	n, err := io.CopyN(dst, src, outSize)
@@ -568,6 +982,13 @@ func (r *rsStream) Join(dst io.Writer, shards []io.Reader, outSize int64) error
 // 'ErrShortData' will be returned if it is unable to retrieve the
 // number of bytes indicated.
 func (r *rsStream) Split(data io.Reader, dst []io.Writer, size int64) error {
+	return r.SplitContext(context.Background(), data, dst, size)
+}
+
+// SplitContext functions as Split, but stops when ctx is done.
+// If ctx is done, ctx.Err() is returned.
+// The streams are not used after SplitContext returns.
+func (r *rsStream) SplitContext(ctx context.Context, data io.Reader, dst []io.Writer, size int64) error {
 	if size == 0 {
 		return ErrShortData
 	}
@@ -586,7 +1007,7 @@ func (r *rsStream) Split(data io.Reader, dst []io.Writer, size int64) error {
 
 	// Pad data to r.Shards*perShard.
 	padding := make([]byte, (int64(r.r.Shards)*perShard)-size)
-	data = io.MultiReader(data, bytes.NewBuffer(padding))
+	data = contextReader{ctx: ctx, r: io.MultiReader(data, bytes.NewBuffer(padding))}
 
 	// Split into equal-length shards and copy.
 	for i := range dst {
@@ -601,3 +1022,121 @@ func (r *rsStream) Split(data io.Reader, dst []io.Writer, size int64) error {
 
 	return nil
 }
+
+// contextReader returns ctx.Err() instead of reading once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// pipeline runs read, code and write of blocks concurrently,
+// with up to r.o.pipeline blocks in flight.
+// read must return io.EOF when there is no more input.
+// Blocks are written in the order they are read.
+// The read and code stages have stopped when pipeline returns.
+func (r *rsStream) pipeline(ctx context.Context, read func(ctx context.Context, all [][]byte) error, code func(all [][]byte) error, write func(ctx context.Context, all [][]byte) error) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	defer wg.Wait()
+	defer cancel()
+
+	free := make(chan [][]byte, r.o.pipeline)
+	for i := 0; i < r.o.pipeline; i++ {
+		free <- r.createSlice()
+	}
+	readC := make(chan [][]byte, r.o.pipeline)
+	codedC := make(chan [][]byte, r.o.pipeline)
+	errs := make(chan error, 2)
+
+	// Read blocks.
+	go func() {
+		defer wg.Done()
+		defer close(readC)
+		for {
+			var all [][]byte
+			select {
+			case all = <-free:
+			case <-ctx.Done():
+				return
+			}
+			for i := range all {
+				all[i] = all[i][:r.o.streamBS]
+			}
+			err := read(ctx, all)
+			if err == io.EOF {
+				free <- all
+				return
+			}
+			if err != nil {
+				errs <- err
+				cancel()
+				return
+			}
+			select {
+			case readC <- all:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Code blocks.
+	go func() {
+		defer wg.Done()
+		defer close(codedC)
+		for all := range readC {
+			if err := code(all); err != nil {
+				errs <- err
+				cancel()
+				return
+			}
+			select {
+			case codedC <- all:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Write blocks.
+	blocks := 0
+	for all := range codedC {
+		if err := write(ctx, all); err != nil {
+			cancel()
+			// If a stage failed, the write was canceled because of it.
+			select {
+			case err := <-errs:
+				return err
+			default:
+			}
+			return err
+		}
+		blocks++
+		free <- all
+	}
+	select {
+	case err := <-errs:
+		return err
+	default:
+	}
+	if err := parent.Err(); err != nil {
+		return err
+	}
+	if blocks == 0 {
+		return ErrShardNoData
+	}
+	// All blocks are back, so they can be reused.
+	for i := 0; i < r.o.pipeline; i++ {
+		r.putSlice(<-free)
+	}
+	return nil
+}
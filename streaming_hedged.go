@@ -0,0 +1,239 @@
+package reedsolomon
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// hedgedBlock is a block read by a hedged shard reader.
+type hedgedBlock struct {
+	shard int
+	block int
+	buf   []byte
+	n     int
+	err   error
+}
+
+// hedgedReader reads blocks from rd into buffers from free
+// and sends them to results until a non-EOF error occurs or done is closed.
+func hedgedReader(shard int, rd io.Reader, free <-chan []byte, results chan<- hedgedBlock, done <-chan struct{}) {
+	for block := 0; ; block++ {
+		var buf []byte
+		select {
+		case buf = <-free:
+		case <-done:
+			return
+		}
+		n, err := io.ReadFull(rd, buf)
+		switch err {
+		case io.EOF, io.ErrUnexpectedEOF:
+			err = nil
+		}
+		select {
+		case results <- hedgedBlock{shard: shard, block: block, buf: buf, n: n, err: err}:
+		case <-done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ReconstructHedged will reconstruct shards from the first responding shards.
+//
+// 'shards' should contain a reader for every available shard, data and parity,
+// with nil for shards that are known to be missing.
+// Every reader is read concurrently, and each block is decoded from the
+// first DataShards readers that deliver it.
+// A slow reader will therefore not stall the stream
+// as long as enough other readers keep up.
+//
+// The complete content of every shard with a non-nil writer in 'fill'
+// is written, no matter if it was read or reconstructed.
+// Supplying writers for the data shards only will therefore join the data
+// from any combination of shards.
+//
+// A reader returning an error other than io.EOF is treated as missing from then on.
+// If timeout is > 0, readers that haven't delivered a block within timeout
+// of the block being requested, and that were not needed to decode the block,
+// are also treated as missing for the rest of the stream.
+// Readers that are needed are waited for, even after the timeout.
+// Readers that have been abandoned are never read again.
+//
+// If too few readers remain, ErrTooFewShards is returned.
+// If ctx is done, ctx.Err() is returned.
+// Reads in progress when ReconstructHedged returns are not waited for,
+// so the readers may still be read after it returns. The same applies to
+// writes if concurrent writes are enabled.
+//
+// Each reader has up to two blocks of read buffers.
+// They are not returned to the allocator, since readers are not waited for.
+func (r *rsStream) ReconstructHedged(ctx context.Context, shards []io.Reader, fill []io.Writer, timeout time.Duration) error {
+	if len(shards) != r.r.Shards || len(fill) != r.r.Shards {
+		return ErrTooFewShards
+	}
+	dead := make([]bool, r.r.Shards)
+	available := 0
+	for i := range shards {
+		if shards[i] == nil {
+			dead[i] = true
+			continue
+		}
+		available++
+	}
+	if available < r.r.DataShards {
+		return ErrTooFewShards
+	}
+	dataOnly := true
+	for i := r.r.DataShards; i < r.r.Shards; i++ {
+		if fill[i] != nil {
+			dataOnly = false
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	results := make(chan hedgedBlock, 2*r.r.Shards)
+	free := make([]chan []byte, r.r.Shards)
+	for i := range shards {
+		if shards[i] == nil {
+			continue
+		}
+		free[i] = make(chan []byte, 2)
+		free[i] <- r.o.alloc(r.o.streamBS)
+		free[i] <- r.o.alloc(r.o.streamBS)
+		go hedgedReader(i, shards[i], free[i], results, done)
+	}
+
+	// The scratch buffers are only used for reconstructed shards.
+	scratch := r.createSlice()
+	defer r.releaseSlice(ctx, scratch)
+	all := make([][]byte, r.r.Shards)
+	chosen := make([]*hedgedBlock, r.r.Shards)
+	ahead := make([][]hedgedBlock, r.r.Shards)
+	delivered := make([]bool, r.r.Shards)
+	read := 0
+	for block := 0; ; block++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i := range chosen {
+			chosen[i] = nil
+			delivered[i] = false
+		}
+		have, size := 0, -1
+		var sizeErr error
+
+		// handle a block result from a reader.
+		handle := func(res hedgedBlock) {
+			switch {
+			case dead[res.shard]:
+				// Abandoned, don't give the buffer back.
+			case res.err != nil:
+				dead[res.shard] = true
+			case res.block < block:
+				// Late for a previous block.
+				free[res.shard] <- res.buf
+			case res.block > block:
+				delivered[res.shard] = true
+				ahead[res.shard] = append(ahead[res.shard], res)
+			case have < r.r.DataShards:
+				delivered[res.shard] = true
+				if size < 0 {
+					size = res.n
+				} else if size != res.n {
+					sizeErr = ErrShardSize
+				}
+				chosen[res.shard] = &res
+				have++
+			default:
+				delivered[res.shard] = true
+				free[res.shard] <- res.buf
+			}
+		}
+		for i := range ahead {
+			if len(ahead[i]) > 0 && ahead[i][0].block == block {
+				res := ahead[i][0]
+				ahead[i] = ahead[i][1:]
+				handle(res)
+			}
+		}
+
+		timedOut := false
+		err := func() error {
+			var timeoutC <-chan time.Time
+			if timeout > 0 {
+				timer := time.NewTimer(timeout)
+				defer timer.Stop()
+				timeoutC = timer.C
+			}
+			for have < r.r.DataShards && sizeErr == nil {
+				// Check if enough readers may still deliver this block.
+				available := have
+				for i := range dead {
+					if !dead[i] && chosen[i] == nil {
+						available++
+					}
+				}
+				if available < r.r.DataShards {
+					return ErrTooFewShards
+				}
+				select {
+				case res := <-results:
+					handle(res)
+				case <-timeoutC:
+					timeoutC = nil
+					timedOut = true
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return sizeErr
+		}()
+		if err != nil {
+			return err
+		}
+		if timedOut {
+			// Abandon the readers that were too slow and not needed.
+			for i := range dead {
+				if !dead[i] && !delivered[i] {
+					dead[i] = true
+				}
+			}
+		}
+		if size == 0 {
+			if read == 0 {
+				return ErrShardNoData
+			}
+			return nil
+		}
+		read += size
+
+		for i := range all {
+			if chosen[i] != nil {
+				all[i] = chosen[i].buf[:size]
+			} else {
+				all[i] = scratch[i][:0]
+			}
+		}
+		if dataOnly {
+			err = r.r.ReconstructData(all)
+		} else {
+			err = r.r.Reconstruct(all)
+		}
+		if err != nil {
+			return err
+		}
+		err = r.writeShards(ctx, fill, all)
+		if err != nil {
+			return err
+		}
+		for i := range chosen {
+			if chosen[i] != nil {
+				free[i] <- chosen[i].buf
+			}
+		}
+	}
+}
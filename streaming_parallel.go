@@ -0,0 +1,189 @@
+package reedsolomon
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// SplitAt splits the input into data shards like Split, but reads
+// the part of each shard directly from its offset in 'data',
+// so several shards are read and written concurrently.
+//
+// The number of shards processed at the same time can be set with
+// WithStreamParallelism. Each shard is copied in blocks of the stream block size.
+//
+// You must supply the total size of your input.
+// 'ErrShortData' will be returned if it is unable to retrieve the
+// number of bytes indicated.
+// If reading the part of data for shard i fails, a StreamReadError for
+// stream i will be returned.
+// If a writer returns an error, a StreamWriteError will be returned.
+// If ctx is done, ctx.Err() is returned.
+func (r *rsStream) SplitAt(ctx context.Context, data io.ReaderAt, dst []io.Writer, size int64) error {
+	if size <= 0 {
+		return ErrShortData
+	}
+	if len(dst) != r.r.DataShards {
+		return ErrInvShardNum
+	}
+	for i := range dst {
+		if dst[i] == nil {
+			return StreamWriteError{Err: ErrShardNoData, Stream: i}
+		}
+	}
+
+	perShard := (size + int64(r.r.DataShards) - 1) / int64(r.r.DataShards)
+	return r.parallelShards(ctx, len(dst), func(ctx context.Context, i int, buf []byte) error {
+		start := int64(i) * perShard
+		for done := int64(0); done < perShard; {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			n := perShard - done
+			if n > int64(len(buf)) {
+				n = int64(len(buf))
+			}
+			b := buf[:n]
+			// The part of the block that is within the input.
+			in := size - start - done
+			if in < 0 {
+				in = 0
+			}
+			if in > n {
+				in = n
+			}
+			if in > 0 {
+				if err := readFullAt(data, b[:in], start+done); err != nil {
+					if err == io.ErrUnexpectedEOF {
+						return ErrShortData
+					}
+					return StreamReadError{Err: err, Stream: i}
+				}
+			}
+			for j := range b[in:] {
+				b[in+int64(j)] = 0
+			}
+			if _, err := dst[i].Write(b); err != nil {
+				return StreamWriteError{Err: err, Stream: i}
+			}
+			done += n
+		}
+		return nil
+	})
+}
+
+// JoinAt joins the data shards like Join, but writes each shard
+// directly to its offset in 'dst', so several shards are read and
+// written concurrently.
+//
+// Only the data shards are considered, and they must have been created by Split,
+// so each shard contains (outSize+DataShards-1)/DataShards bytes of data.
+// The number of shards processed at the same time can be set with
+// WithStreamParallelism.
+//
+// You must supply the exact output size you want.
+// If there are to few shards given, ErrTooFewShards will be returned.
+// If the total data size is less than outSize, ErrShortData will be returned.
+// If a reader returns an error, a StreamReadError will be returned.
+// If writing shard i to dst fails, a StreamWriteError for stream i will be returned.
+// If ctx is done, ctx.Err() is returned.
+func (r *rsStream) JoinAt(ctx context.Context, dst io.WriterAt, shards []io.Reader, outSize int64) error {
+	if len(shards) < r.r.DataShards {
+		return ErrTooFewShards
+	}
+	if outSize <= 0 {
+		return ErrShortData
+	}
+	shards = shards[:r.r.DataShards]
+	for i := range shards {
+		if shards[i] == nil {
+			return StreamReadError{Err: ErrShardNoData, Stream: i}
+		}
+	}
+
+	perShard := (outSize + int64(r.r.DataShards) - 1) / int64(r.r.DataShards)
+	return r.parallelShards(ctx, len(shards), func(ctx context.Context, i int, buf []byte) error {
+		start := int64(i) * perShard
+		want := outSize - start
+		if want > perShard {
+			want = perShard
+		}
+		for done := int64(0); done < want; {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			n := want - done
+			if n > int64(len(buf)) {
+				n = int64(len(buf))
+			}
+			b := buf[:n]
+			if _, err := io.ReadFull(shards[i], b); err != nil {
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					return ErrShortData
+				}
+				return StreamReadError{Err: err, Stream: i}
+			}
+			if err := writeFullAt(dst, b, start+done); err != nil {
+				return StreamWriteError{Err: err, Stream: i}
+			}
+			done += n
+		}
+		return nil
+	})
+}
+
+// parallelShards calls fn for shards 0 to n-1, running up to
+// the configured parallelism concurrently.
+// Each call gets a buffer of the stream block size.
+// The first error is returned, and cancels the context given to the remaining calls.
+func (r *rsStream) parallelShards(ctx context.Context, n int, fn func(ctx context.Context, i int, buf []byte) error) error {
+	workers := r.o.streamParallelism
+	if workers <= 0 || workers > n {
+		workers = n
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	done := 0
+	next := make(chan int, n)
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			buf := r.o.alloc(r.o.streamBS)
+			defer r.o.free(buf)
+			for i := range next {
+				if ctx.Err() != nil {
+					return
+				}
+				err := fn(ctx, i, buf)
+				mu.Lock()
+				if err == nil {
+					done++
+				} else if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				if err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr == nil && done < n {
+		// Stopped because the parent context is done.
+		firstErr = parent.Err()
+	}
+	return firstErr
+}
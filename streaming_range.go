@@ -0,0 +1,141 @@
+package reedsolomon
+
+import (
+	"io"
+)
+
+// EncodeRange will update parity like Encode, but only for the
+// 'length' bytes starting at 'offset' in every shard.
+//
+// Data is read from 'data' and parity is written to 'parity' at
+// the same offsets. Parity outside the range is not modified.
+// The range is processed in blocks of the stream block size.
+//
+// If a data shard cannot supply the range, a StreamReadError type error
+// will be returned. If a parity writer returns an error, a
+// StreamWriteError will be returned.
+func (r *rsStream) EncodeRange(data []io.ReaderAt, parity []io.WriterAt, offset, length int64) error {
+	if len(data) != r.r.DataShards || len(parity) != r.r.ParityShards {
+		return ErrTooFewShards
+	}
+	if offset < 0 || length <= 0 || offset+length < offset {
+		return ErrInvalidRange
+	}
+	for i := range data {
+		if data[i] == nil {
+			return StreamReadError{Err: ErrShardNoData, Stream: i}
+		}
+	}
+	for i := range parity {
+		if parity[i] == nil {
+			return StreamWriteError{Err: ErrShardNoData, Stream: i}
+		}
+	}
+
+	all := r.createSlice()
+	defer r.putSlice(all)
+	for done := int64(0); done < length; {
+		n := length - done
+		if n > int64(r.o.streamBS) {
+			n = int64(r.o.streamBS)
+		}
+		off := offset + done
+		for i := range all {
+			all[i] = all[i][:n]
+		}
+		for i := range data {
+			if err := readFullAt(data[i], all[i], off); err != nil {
+				return StreamReadError{Err: err, Stream: i}
+			}
+		}
+		if err := r.r.Encode(all); err != nil {
+			return err
+		}
+		for i := range parity {
+			if err := writeFullAt(parity[i], all[r.r.DataShards+i], off); err != nil {
+				return StreamWriteError{Err: err, Stream: i}
+			}
+		}
+		done += n
+	}
+	return nil
+}
+
+// ReconstructRange will recreate missing shards like Reconstruct,
+// but only for the 'length' bytes starting at 'offset'.
+//
+// You indicate that a shard is missing by setting it to nil in the 'valid'
+// slice and at the same time setting a non-nil writer in "fill".
+// An index cannot contain both non-nil 'valid' and 'fill' entry.
+// If both are provided 'ErrReconstructMismatch' is returned.
+//
+// The range is read from the valid shards and the reconstructed
+// range is written to the fill writers at the same offsets.
+//
+// If there are too few shards to reconstruct the missing
+// ones, ErrTooFewShards will be returned.
+func (r *rsStream) ReconstructRange(valid []io.ReaderAt, fill []io.WriterAt, offset, length int64) error {
+	if len(valid) != r.r.Shards || len(fill) != r.r.Shards {
+		return ErrTooFewShards
+	}
+	if offset < 0 || length <= 0 || offset+length < offset {
+		return ErrInvalidRange
+	}
+	reconDataOnly := true
+	for i := range valid {
+		if valid[i] != nil && fill[i] != nil {
+			return ErrReconstructMismatch
+		}
+		if i >= r.r.DataShards && fill[i] != nil {
+			reconDataOnly = false
+		}
+	}
+
+	all := r.createSlice()
+	defer r.putSlice(all)
+	for done := int64(0); done < length; {
+		n := length - done
+		if n > int64(r.o.streamBS) {
+			n = int64(r.o.streamBS)
+		}
+		off := offset + done
+		for i := range all {
+			if valid[i] == nil {
+				all[i] = all[i][:0]
+				continue
+			}
+			all[i] = all[i][:n]
+			if err := readFullAt(valid[i], all[i], off); err != nil {
+				return StreamReadError{Err: err, Stream: i}
+			}
+		}
+		var err error
+		if reconDataOnly {
+			err = r.r.ReconstructData(all)
+		} else {
+			err = r.r.Reconstruct(all)
+		}
+		if err != nil {
+			return err
+		}
+		for i := range fill {
+			if fill[i] == nil {
+				continue
+			}
+			if err := writeFullAt(fill[i], all[i], off); err != nil {
+				return StreamWriteError{Err: err, Stream: i}
+			}
+		}
+		done += n
+	}
+	return nil
+}
+
+// writeFullAt writes all of p to w at offset off.
+func writeFullAt(w io.WriterAt, p []byte, off int64) error {
+	n, err := w.WriteAt(p, off)
+	if err == nil && n != len(p) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package reedsolomon
+
+import (
+	"errors"
+	"io"
+)
+
+// errNegativeOffset is returned when reading at a negative offset.
+var errNegativeOffset = errors.New("negative offset")
+
+// JoinReader returns a reader for the data that was split into the shards.
+//
+// 'shards' must contain an io.ReaderAt for every shard, data and parity,
+// with nil for shards that are missing.
+// Each shard must be laid out as created by Split, and size must be
+// the size of the original data.
+//
+// Reads of ranges on present data shards are served directly.
+// If a data shard is missing or returns an error, only the requested range
+// is reconstructed from the same range of DataShards other shards.
+// Reconstruction is done in blocks of up to the stream block size.
+//
+// The returned reader is safe for concurrent use with ReadAt,
+// if the supplied shard readers are.
+func (r *rsStream) JoinReader(shards []io.ReaderAt, size int64) (*io.SectionReader, error) {
+	if len(shards) != r.r.Shards {
+		return nil, ErrTooFewShards
+	}
+	if size <= 0 {
+		return nil, ErrShortData
+	}
+	present := 0
+	for i := range shards {
+		if shards[i] != nil {
+			present++
+		}
+	}
+	if present < r.r.DataShards {
+		return nil, ErrTooFewShards
+	}
+	j := &joinReaderAt{
+		r:        r,
+		shards:   shards,
+		size:     size,
+		perShard: (size + int64(r.r.DataShards) - 1) / int64(r.r.DataShards),
+	}
+	return io.NewSectionReader(j, 0, size), nil
+}
+
+// joinReaderAt reads joined data shards, reconstructing missing ranges.
+type joinReaderAt struct {
+	r        *rsStream
+	shards   []io.ReaderAt
+	size     int64
+	perShard int64
+}
+
+// ReadAt reads len(p) bytes of joined data starting at off.
+func (j *joinReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	if off >= j.size {
+		return 0, io.EOF
+	}
+	if remain := j.size - off; int64(len(p)) > remain {
+		p = p[:remain]
+		err = io.EOF
+	}
+	for len(p) > 0 {
+		shard := int(off / j.perShard)
+		shardOff := off % j.perShard
+		todo := len(p)
+		if left := j.perShard - shardOff; int64(todo) > left {
+			todo = int(left)
+		}
+		if todo > j.r.o.streamBS {
+			todo = j.r.o.streamBS
+		}
+		if rerr := j.readShard(shard, shardOff, p[:todo]); rerr != nil {
+			return n, rerr
+		}
+		n += todo
+		off += int64(todo)
+		p = p[todo:]
+	}
+	return n, err
+}
+
+// readShard reads data shard idx at offset off into dst.
+// If the shard cannot be read, the range is reconstructed.
+func (j *joinReaderAt) readShard(idx int, off int64, dst []byte) error {
+	if rd := j.shards[idx]; rd != nil && readFullAt(rd, dst, off) == nil {
+		return nil
+	}
+
+	r := j.r.r
+	all := j.r.createSlice()
+	defer j.r.putSlice(all)
+	inputs := make([][]byte, 0, r.DataShards)
+	validIndices := make([]int, 0, r.DataShards)
+	invalidIndices := make([]int, 0)
+	var readErr error
+	for i := 0; i < r.Shards && len(validIndices) < r.DataShards; i++ {
+		if i == idx || j.shards[i] == nil {
+			invalidIndices = append(invalidIndices, i)
+			continue
+		}
+		buf := all[i][:len(dst)]
+		if err := readFullAt(j.shards[i], buf, off); err != nil {
+			readErr = StreamReadError{Err: err, Stream: i}
+			invalidIndices = append(invalidIndices, i)
+			continue
+		}
+		inputs = append(inputs, buf)
+		validIndices = append(validIndices, i)
+	}
+	if len(validIndices) < r.DataShards {
+		if readErr != nil {
+			return readErr
+		}
+		return ErrTooFewShards
+	}
+	dataDecodeMatrix, err := r.getDataDecodeMatrix(validIndices, invalidIndices)
+	if err != nil {
+		return err
+	}
+	r.codeSomeShards([][]byte{dataDecodeMatrix[idx]}, inputs, [][]byte{dst}, len(dst))
+	return nil
+}
+
+// readFullAt reads exactly len(p) bytes from r at offset off.
+func readFullAt(r io.ReaderAt, p []byte, off int64) error {
+	n, err := r.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -8,10 +8,14 @@ package reedsolomon
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"math/rand"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestStreamEncoding(t *testing.T) {
@@ -700,3 +704,781 @@ func TestNewStream(t *testing.T) {
 		}
 	}
 }
+
+// blockingReader blocks until unblock is closed.
+type blockingReader struct {
+	unblock chan struct{}
+}
+
+func (b blockingReader) Read(p []byte) (int, error) {
+	<-b.unblock
+	return 0, io.EOF
+}
+
+// cancelReader calls cancel when more than n bytes have been read.
+type cancelReader struct {
+	r      io.Reader
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancelReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n -= n
+	if c.n < 0 {
+		c.cancel()
+	}
+	return n, err
+}
+
+func TestStreamContext(t *testing.T) {
+	const dataShards, parityShards, size = 5, 3, 100000
+	for _, conc := range []bool{false, true} {
+		enc, err := NewStreamC(dataShards, parityShards, conc, conc, testOptions(WithStreamBlockSize(10000))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		data := randomBytes(dataShards, size)
+		parity := emptyBuffers(parityShards)
+		if err := enc.(StreamContextEncoder).EncodeContext(cancelled, toReaders(toBuffers(data)), toWriters(parity)); err != context.Canceled {
+			t.Errorf("encode: expected %v, got %v", context.Canceled, err)
+		}
+		all := append(toBuffers(data), emptyBuffers(parityShards)...)
+		if ok, err := enc.(StreamContextEncoder).VerifyContext(cancelled, toReaders(all)); ok || err != context.Canceled {
+			t.Errorf("verify: expected %v, got %v, %v", context.Canceled, ok, err)
+		}
+		valid := toReaders(toBuffers(append(data, randomBytes(parityShards, size)...)))
+		fill := make([]io.Writer, dataShards+parityShards)
+		valid[0], fill[0] = nil, new(bytes.Buffer)
+		if err := enc.(StreamContextEncoder).ReconstructContext(cancelled, valid, fill); err != context.Canceled {
+			t.Errorf("reconstruct: expected %v, got %v", context.Canceled, err)
+		}
+		if err := enc.(StreamContextEncoder).SplitContext(cancelled, randomBuffer(size), toWriters(emptyBuffers(dataShards)), size); err != context.Canceled {
+			t.Errorf("split: expected %v, got %v", context.Canceled, err)
+		}
+		if err := enc.(StreamContextEncoder).JoinContext(cancelled, new(bytes.Buffer), toReaders(toBuffers(data)), size); err != context.Canceled {
+			t.Errorf("join: expected %v, got %v", context.Canceled, err)
+		}
+
+		// Cancel in the middle of the stream.
+		ctx, cancel := context.WithCancel(context.Background())
+		readers := toReaders(toBuffers(data))
+		readers[0] = &cancelReader{r: readers[0], n: size / 2, cancel: cancel}
+		parity = emptyBuffers(parityShards)
+		if err := enc.(StreamContextEncoder).EncodeContext(ctx, readers, toWriters(parity)); err != context.Canceled {
+			t.Errorf("encode: expected %v, got %v", context.Canceled, err)
+		}
+		// Abandoned concurrent writes may still be running.
+		if !conc {
+			if n := parity[0].Len(); n == 0 || n >= size {
+				t.Errorf("expected partial output, got %d bytes", n)
+			}
+		}
+
+		// Without cancellation the result must match the non-context version.
+		parity = emptyBuffers(parityShards)
+		if err := enc.(StreamContextEncoder).EncodeContext(context.Background(), toReaders(toBuffers(data)), toWriters(parity)); err != nil {
+			t.Fatal(err)
+		}
+		want := emptyBuffers(parityShards)
+		if err := enc.Encode(toReaders(toBuffers(data)), toWriters(want)); err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			if !bytes.Equal(parity[i].Bytes(), want[i].Bytes()) {
+				t.Errorf("parity %d mismatch", i)
+			}
+		}
+	}
+
+	// Concurrent reads blocked in a reader must be abandoned.
+	enc, err := NewStreamC(dataShards, parityShards, true, true, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unblock := make(chan struct{})
+	defer close(unblock)
+	readers := toReaders(toBuffers(randomBytes(dataShards, size)))
+	readers[1] = blockingReader{unblock: unblock}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- enc.(StreamContextEncoder).EncodeContext(ctx, readers, toWriters(emptyBuffers(parityShards)))
+	}()
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("encode was not cancelled")
+	}
+}
+
+// slowReader sleeps before every read.
+type slowReader struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (s slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.r.Read(p)
+}
+
+// lateReader sleeps once, before reading the byte at offset 'at'.
+type lateReader struct {
+	r     io.Reader
+	at    int
+	delay time.Duration
+	read  int
+}
+
+func (l *lateReader) Read(p []byte) (int, error) {
+	if l.read <= l.at && l.read+len(p) > l.at {
+		time.Sleep(l.delay)
+	}
+	n, err := l.r.Read(p)
+	l.read += n
+	return n, err
+}
+
+// failingReader returns an error after n bytes.
+type failingReader struct {
+	r io.Reader
+	n int
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, errors.New("read failed")
+	}
+	if len(p) > f.n {
+		p = p[:f.n]
+	}
+	n, err := f.r.Read(p)
+	f.n -= n
+	return n, err
+}
+
+func TestStreamReconstructHedged(t *testing.T) {
+	const dataShards, parityShards, size = 5, 3, 25000
+	enc, err := NewStream(dataShards, parityShards, testOptions(WithStreamBlockSize(1000))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := randomBytes(dataShards+parityShards, size)
+	parity := emptyBuffers(parityShards)
+	err = enc.Encode(toReaders(toBuffers(shards[:dataShards])), toWriters(parity))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(shards[dataShards:], toBytes(parity))
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+	readers := toReaders(toBuffers(shards))
+	readers[0] = nil
+	readers[1] = &failingReader{r: readers[1], n: size / 2}
+	readers[2] = slowReader{r: readers[2], delay: time.Millisecond}
+	readers[6] = blockingReader{unblock: unblock}
+	fill := emptyBuffers(dataShards + parityShards)
+	err = enc.(StreamHedgedEncoder).ReconstructHedged(context.Background(), readers, toWriters(fill), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, got := range toBytes(fill) {
+		if !bytes.Equal(got, shards[i]) {
+			t.Errorf("shard %d mismatch, got %d bytes", i, len(got))
+		}
+	}
+
+	// Data shards only.
+	readers = toReaders(toBuffers(shards))
+	readers[3] = nil
+	fill = emptyBuffers(dataShards)
+	err = enc.(StreamHedgedEncoder).ReconstructHedged(context.Background(), readers, append(toWriters(fill), nilWriters(parityShards)...), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, got := range toBytes(fill) {
+		if !bytes.Equal(got, shards[i]) {
+			t.Errorf("shard %d mismatch, got %d bytes", i, len(got))
+		}
+	}
+
+	// The blocked reader is not needed, so it is abandoned after the timeout.
+	readers = toReaders(toBuffers(shards))
+	readers[0], readers[1] = nil, nil
+	readers[6] = blockingReader{unblock: unblock}
+	fill = emptyBuffers(dataShards)
+	err = enc.(StreamHedgedEncoder).ReconstructHedged(context.Background(), readers, append(toWriters(fill), nilWriters(parityShards)...), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, got := range toBytes(fill) {
+		if !bytes.Equal(got, shards[i]) {
+			t.Errorf("shard %d mismatch, got %d bytes", i, len(got))
+		}
+	}
+
+	// A reader that is late for one block is waited for if it is needed.
+	readers = toReaders(toBuffers(shards))
+	readers[0], readers[1], readers[2] = nil, nil, nil
+	readers[6] = &lateReader{r: readers[6], at: size / 2, delay: 20 * time.Millisecond}
+	fill = emptyBuffers(dataShards + parityShards)
+	err = enc.(StreamHedgedEncoder).ReconstructHedged(context.Background(), readers, toWriters(fill), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, got := range toBytes(fill) {
+		if !bytes.Equal(got, shards[i]) {
+			t.Errorf("shard %d mismatch, got %d bytes", i, len(got))
+		}
+	}
+
+	// The blocked reader is needed, so only the context can stop it.
+	readers = toReaders(toBuffers(shards))
+	readers[0], readers[1], readers[2] = nil, nil, nil
+	readers[6] = blockingReader{unblock: unblock}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = enc.(StreamHedgedEncoder).ReconstructHedged(ctx, readers, toWriters(emptyBuffers(dataShards+parityShards)), time.Millisecond)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	// Without timeout, the context must stop it.
+	readers = toReaders(toBuffers(shards))
+	readers[0], readers[1], readers[2] = nil, nil, nil
+	readers[6] = blockingReader{unblock: unblock}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = enc.(StreamHedgedEncoder).ReconstructHedged(ctx, readers, toWriters(emptyBuffers(dataShards+parityShards)), 0)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	readers = toReaders(toBuffers(shards))
+	readers[0], readers[1], readers[2], readers[3] = nil, nil, nil, nil
+	err = enc.(StreamHedgedEncoder).ReconstructHedged(context.Background(), readers, toWriters(emptyBuffers(dataShards+parityShards)), 0)
+	if err != ErrTooFewShards {
+		t.Errorf("expected %v, got %v", ErrTooFewShards, err)
+	}
+
+	err = enc.(StreamHedgedEncoder).ReconstructHedged(context.Background(), toReaders(emptyBuffers(dataShards+parityShards)), nilWriters(dataShards+parityShards), 0)
+	if err != ErrShardNoData {
+		t.Errorf("expected %v, got %v", ErrShardNoData, err)
+	}
+}
+
+// failingReaderAt fails all reads.
+type failingReaderAt struct{}
+
+func (failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestStreamJoinReader(t *testing.T) {
+	const dataShards, parityShards, size = 5, 3, 12345
+	data := make([]byte, size)
+	fillRandom(data)
+	enc, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards, err := enc.Split(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	senc, err := NewStream(dataShards, parityShards, testOptions(WithStreamBlockSize(100))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readers := make([]io.ReaderAt, len(shards))
+	for i := range shards {
+		readers[i] = bytes.NewReader(shards[i])
+	}
+	readers[1] = nil
+	readers[3] = failingReaderAt{}
+
+	rd, err := senc.(StreamReaderAtEncoder).JoinReader(readers, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("joined data mismatch")
+	}
+	rng := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		off := rng.Int63n(size)
+		buf := make([]byte, rng.Intn(3000))
+		n, err := rd.ReadAt(buf, off)
+		want := data[off:]
+		if len(want) > len(buf) {
+			want = want[:len(buf)]
+		} else if err != io.EOF {
+			t.Errorf("expected EOF at end of data, got %v", err)
+		}
+		if n != len(want) || !bytes.Equal(buf[:n], want) {
+			t.Fatalf("mismatch reading %d bytes at %d", len(buf), off)
+		}
+	}
+	if _, err := rd.Seek(size-10, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ioutil.ReadAll(rd)
+	if err != nil || !bytes.Equal(got, data[size-10:]) {
+		t.Errorf("mismatch after seek, err: %v", err)
+	}
+
+	// Too many failing shards are only detected when reading.
+	readers[0], readers[2] = failingReaderAt{}, failingReaderAt{}
+	rd, err = senc.(StreamReaderAtEncoder).JoinReader(readers, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rd.ReadAt(make([]byte, 10), 0)
+	if _, ok := err.(StreamReadError); !ok {
+		t.Errorf("expected error type %T, got %T", StreamReadError{}, err)
+	}
+	readers[0], readers[2], readers[4] = nil, nil, nil
+	if _, err := senc.(StreamReaderAtEncoder).JoinReader(readers, size); err != ErrTooFewShards {
+		t.Errorf("expected %v, got %v", ErrTooFewShards, err)
+	}
+	if _, err := senc.(StreamReaderAtEncoder).JoinReader(readers[:4], size); err != ErrTooFewShards {
+		t.Errorf("expected %v, got %v", ErrTooFewShards, err)
+	}
+}
+
+// errorWriter fails all writes.
+type errorWriter struct{}
+
+func (errorWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestStreamPipeline(t *testing.T) {
+	const dataShards, parityShards, size = 5, 3, 25500
+	ref, err := NewStream(dataShards, parityShards, testOptions(WithStreamBlockSize(1000))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := randomBytes(dataShards, size)
+	want := emptyBuffers(parityShards)
+	if err := ref.Encode(toReaders(toBuffers(input)), toWriters(want)); err != nil {
+		t.Fatal(err)
+	}
+	for _, conc := range []bool{false, true} {
+		r, err := NewStreamC(dataShards, parityShards, conc, conc, testOptions(WithStreamBlockSize(1000), WithStreamPipeline(3))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		par := emptyBuffers(parityShards)
+		if err := r.Encode(toReaders(toBuffers(input)), toWriters(par)); err != nil {
+			t.Fatal(err)
+		}
+		for i := range par {
+			if !bytes.Equal(par[i].Bytes(), want[i].Bytes()) {
+				t.Fatalf("parity %d mismatch", i)
+			}
+		}
+
+		all := append(input, toBytes(par)...)
+		for _, missing := range [][]int{{0, 2}, {1, 6, 7}, {7}} {
+			valid := toReaders(toBuffers(all))
+			fill := make([]io.Writer, len(all))
+			bufs := emptyBuffers(len(all))
+			for _, i := range missing {
+				valid[i] = nil
+				fill[i] = bufs[i]
+			}
+			if err := r.Reconstruct(valid, fill); err != nil {
+				t.Fatal(err)
+			}
+			for _, i := range missing {
+				if !bytes.Equal(bufs[i].Bytes(), all[i]) {
+					t.Errorf("reconstructed shard %d mismatch", i)
+				}
+			}
+		}
+
+		err = r.Encode(toReaders(emptyBuffers(dataShards)), toWriters(emptyBuffers(parityShards)))
+		if err != ErrShardNoData {
+			t.Errorf("expected %v, got %v", ErrShardNoData, err)
+		}
+		badShards := toBuffers(input)
+		badShards[0] = randomBuffer(123)
+		err = r.Encode(toReaders(badShards), toWriters(emptyBuffers(parityShards)))
+		if err != ErrShardSize {
+			t.Errorf("expected %v, got %v", ErrShardSize, err)
+		}
+		writers := toWriters(emptyBuffers(parityShards))
+		writers[1] = errorWriter{}
+		err = r.Encode(toReaders(toBuffers(input)), writers)
+		if se, ok := err.(StreamWriteError); !ok || se.Stream != 1 {
+			t.Errorf("expected write error on stream 1, got %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = r.(StreamContextEncoder).EncodeContext(ctx, toReaders(toBuffers(input)), toWriters(emptyBuffers(parityShards)))
+		if err != context.Canceled {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+	}
+}
+
+// slowWriter sleeps before every write.
+type slowWriter struct {
+	w io.Writer
+	d time.Duration
+}
+
+func (s slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(s.d)
+	return s.w.Write(p)
+}
+
+func TestStreamPipelineReadError(t *testing.T) {
+	const dataShards, parityShards, size, bs = 2, 2, 10000, 1000
+	for _, conc := range []bool{false, true} {
+		r, err := NewStreamC(dataShards, parityShards, conc, conc, testOptions(WithStreamBlockSize(bs), WithStreamPipeline(4))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		readers := toReaders(toBuffers(randomBytes(dataShards, size)))
+		readers[1] = &failingReader{r: readers[1], n: 3 * bs}
+		writers := toWriters(emptyBuffers(parityShards))
+		for i := range writers {
+			writers[i] = slowWriter{w: writers[i], d: 10 * time.Millisecond}
+		}
+		err = r.Encode(readers, writers)
+		if se, ok := err.(StreamReadError); !ok || se.Stream != 1 {
+			t.Errorf("concurrent %v: expected read error on stream 1, got %v", conc, err)
+		}
+	}
+}
+
+func TestStreamVerifyBlocks(t *testing.T) {
+	const dataShards, parityShards, size, bs = 5, 3, 10500, 1000
+	r, err := NewStream(dataShards, parityShards, testOptions(WithStreamBlockSize(bs))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := randomBytes(dataShards, size)
+	par := emptyBuffers(parityShards)
+	if err := r.Encode(toReaders(toBuffers(input)), toWriters(par)); err != nil {
+		t.Fatal(err)
+	}
+	all := append(input, toBytes(par)...)
+
+	res, err := r.(StreamBlockVerifier).VerifyBlocks(context.Background(), toReaders(toBuffers(all)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK() || res.Size != size {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	// Corrupt a parity shard in block 2, and a data shard in the last block.
+	all[6][2*bs+10]++
+	all[1][size-1]++
+	res, err = r.(StreamBlockVerifier).VerifyBlocks(context.Background(), toReaders(toBuffers(all)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StreamMismatch{
+		{Block: 2, Offset: 2 * bs, Size: bs, Parity: []int{6}},
+		{Block: 10, Offset: 10 * bs, Size: size - 10*bs, Parity: []int{5, 6, 7}},
+	}
+	if res.OK() || res.Size != size || !reflect.DeepEqual(res.Mismatches, want) {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	res, err = r.(StreamBlockVerifier).VerifyBlocks(context.Background(), toReaders(toBuffers(all)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Size != 3*bs || !reflect.DeepEqual(res.Mismatches, want[:1]) {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	_, err = r.(StreamBlockVerifier).VerifyBlocks(context.Background(), toReaders(emptyBuffers(dataShards+parityShards)), true)
+	if err != ErrShardNoData {
+		t.Errorf("expected %v, got %v", ErrShardNoData, err)
+	}
+	_, err = r.(StreamBlockVerifier).VerifyBlocks(context.Background(), toReaders(emptyBuffers(dataShards)), true)
+	if err != ErrTooFewShards {
+		t.Errorf("expected %v, got %v", ErrTooFewShards, err)
+	}
+}
+
+// memWriterAt is an io.WriterAt writing to a fixed size buffer.
+type memWriterAt []byte
+
+func (m memWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(m)) {
+		return 0, io.ErrShortWrite
+	}
+	return copy(m[off:], p), nil
+}
+
+func TestStreamRange(t *testing.T) {
+	const dataShards, parityShards, size = 5, 3, 10000
+	enc, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := randomBytes(dataShards+parityShards, size)
+	if err := enc.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewStream(dataShards, parityShards, testOptions(WithStreamBlockSize(1000))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite a range of the data and update parity.
+	const offset, length = 1500, 3210
+	for i := 0; i < dataShards; i++ {
+		fillRandom(shards[i][offset : offset+length])
+	}
+	data := make([]io.ReaderAt, dataShards)
+	for i := range data {
+		data[i] = bytes.NewReader(shards[i])
+	}
+	parity := make([]io.WriterAt, parityShards)
+	for i := range parity {
+		parity[i] = memWriterAt(shards[dataShards+i])
+	}
+	if err := r.(StreamReaderAtEncoder).EncodeRange(data, parity, offset, length); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := enc.Verify(shards); !ok || err != nil {
+		t.Fatal("verification failed", err)
+	}
+
+	// Lose a range of a data and a parity shard.
+	valid := make([]io.ReaderAt, len(shards))
+	fill := make([]io.WriterAt, len(shards))
+	lost := make([][]byte, len(shards))
+	for i := range shards {
+		valid[i] = bytes.NewReader(shards[i])
+	}
+	for _, i := range []int{2, 7} {
+		lost[i] = make([]byte, size)
+		valid[i], fill[i] = nil, memWriterAt(lost[i])
+	}
+	if err := r.(StreamReaderAtEncoder).ReconstructRange(valid, fill, offset, length); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{2, 7} {
+		if !bytes.Equal(lost[i][offset:offset+length], shards[i][offset:offset+length]) {
+			t.Errorf("shard %d mismatch", i)
+		}
+	}
+
+	valid[2] = bytes.NewReader(shards[2])
+	if err := r.(StreamReaderAtEncoder).ReconstructRange(valid, fill, offset, length); err != ErrReconstructMismatch {
+		t.Errorf("expected %v, got %v", ErrReconstructMismatch, err)
+	}
+	if err := r.(StreamReaderAtEncoder).EncodeRange(data, parity, size-10, 20); err == nil {
+		t.Error("expected error reading outside shards")
+	}
+	if err := r.(StreamReaderAtEncoder).EncodeRange(data, parity, -1, 20); err != ErrInvalidRange {
+		t.Errorf("expected %v, got %v", ErrInvalidRange, err)
+	}
+}
+
+// memFile is an in-memory io.WriteSeeker.
+type memFile struct {
+	buf []byte
+	pos int64
+}
+
+func (m *memFile) Write(p []byte) (int, error) {
+	if end := m.pos + int64(len(p)); end > int64(len(m.buf)) {
+		m.buf = append(m.buf, make([]byte, end-int64(len(m.buf)))...)
+	}
+	n := copy(m.buf[m.pos:], p)
+	m.pos += int64(n)
+	return n, nil
+}
+
+func (m *memFile) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, errors.New("unsupported whence")
+	}
+	m.pos = offset
+	return offset, nil
+}
+
+func TestStreamEncodeResumable(t *testing.T) {
+	const dataShards, parityShards, size, bs = 5, 3, 10500, 1000
+	input := randomBytes(dataShards, size)
+	for _, pipeline := range []int{0, 3} {
+		r, err := NewStream(dataShards, parityShards, testOptions(WithStreamBlockSize(bs), WithStreamPipeline(pipeline))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := emptyBuffers(parityShards)
+		if err := r.Encode(toReaders(toBuffers(input)), toWriters(want)); err != nil {
+			t.Fatal(err)
+		}
+
+		// Stop after a number of blocks.
+		files := make([]*memFile, parityShards)
+		writers := make([]io.Writer, parityShards)
+		for i := range files {
+			files[i] = &memFile{}
+			writers[i] = files[i]
+		}
+		readers := make([]io.Reader, dataShards)
+		for i := range readers {
+			readers[i] = bytes.NewReader(input[i])
+		}
+		errStop := errors.New("stop")
+		var last StreamCheckpoint
+		err = r.(StreamResumableEncoder).EncodeResumable(context.Background(), readers, writers, 0, func(cp StreamCheckpoint) error {
+			last = cp
+			if cp.Blocks == 4 {
+				return errStop
+			}
+			return nil
+		})
+		if err != errStop {
+			t.Fatalf("expected %v, got %v", errStop, err)
+		}
+		if last.Offset != 4*bs {
+			t.Fatalf("unexpected checkpoint %+v", last)
+		}
+
+		// Resume with fresh readers.
+		for i := range readers {
+			readers[i] = bytes.NewReader(input[i])
+		}
+		var calls int
+		err = r.(StreamResumableEncoder).EncodeResumable(context.Background(), readers, writers, last.Offset, func(cp StreamCheckpoint) error {
+			calls++
+			last = cp
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.Offset != size || calls != 7 {
+			t.Errorf("unexpected final checkpoint %+v after %d calls", last, calls)
+		}
+		for i := range files {
+			if !bytes.Equal(files[i].buf, want[i].Bytes()) {
+				t.Errorf("parity %d mismatch", i)
+			}
+		}
+
+		// Resuming a finished encode does nothing.
+		err = r.(StreamResumableEncoder).EncodeResumable(context.Background(), readers, writers, size, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		err = r.(StreamResumableEncoder).EncodeResumable(context.Background(), readers, writers, -1, nil)
+		if err != ErrInvalidRange {
+			t.Errorf("expected %v, got %v", ErrInvalidRange, err)
+		}
+	}
+}
+
+// failingWriterAt returns an error on every write.
+type failingWriterAt struct{}
+
+func (failingWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestStreamSplitJoinAt(t *testing.T) {
+	const dataShards, parityShards = 5, 3
+	for _, size := range []int{1, 4, 999, 10000, 25001} {
+		for _, parallel := range []int{0, 1, 2} {
+			r, err := NewStream(dataShards, parityShards, testOptions(WithStreamBlockSize(1000), WithStreamParallelism(parallel))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := make([]byte, size)
+			fillRandom(data)
+
+			// Compare with Split.
+			want := emptyBuffers(dataShards)
+			if err := r.Split(bytes.NewReader(data), toWriters(want), int64(size)); err != nil {
+				t.Fatal(err)
+			}
+			got := emptyBuffers(dataShards)
+			if err := r.(StreamReaderAtEncoder).SplitAt(context.Background(), bytes.NewReader(data), toWriters(got), int64(size)); err != nil {
+				t.Fatal(err)
+			}
+			for i := range got {
+				if !bytes.Equal(got[i].Bytes(), want[i].Bytes()) {
+					t.Fatalf("size %d, parallel %d: shard %d mismatch", size, parallel, i)
+				}
+			}
+
+			dst := make(memWriterAt, size)
+			if err := r.(StreamReaderAtEncoder).JoinAt(context.Background(), dst, toReaders(got), int64(size)); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(dst, data) {
+				t.Fatalf("size %d, parallel %d: joined data mismatch", size, parallel)
+			}
+		}
+	}
+
+	r, err := NewStream(dataShards, parityShards, testOptions(WithStreamBlockSize(1000))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 10000)
+	err = r.(StreamReaderAtEncoder).SplitAt(context.Background(), bytes.NewReader(data[:5000]), toWriters(emptyBuffers(dataShards)), 10000)
+	if err != ErrShortData {
+		t.Errorf("expected %v, got %v", ErrShortData, err)
+	}
+	dst := emptyBuffers(dataShards)
+	dstW := toWriters(dst)
+	dstW[2] = errorWriter{}
+	err = r.(StreamReaderAtEncoder).SplitAt(context.Background(), bytes.NewReader(data), dstW, 10000)
+	if se, ok := err.(StreamWriteError); !ok || se.Stream != 2 {
+		t.Errorf("expected StreamWriteError on stream 2, got %v", err)
+	}
+	shards := toBuffers(randomBytes(dataShards, 2000))
+	err = r.(StreamReaderAtEncoder).JoinAt(context.Background(), make(memWriterAt, 20000), toReaders(shards), 20000)
+	if err != ErrShortData {
+		t.Errorf("expected %v, got %v", ErrShortData, err)
+	}
+	err = r.(StreamReaderAtEncoder).SplitAt(context.Background(), failingReaderAt{}, toWriters(emptyBuffers(dataShards)), 10000)
+	if _, ok := err.(StreamReadError); !ok {
+		t.Errorf("expected StreamReadError, got %v", err)
+	}
+	err = r.(StreamReaderAtEncoder).JoinAt(context.Background(), failingWriterAt{}, toReaders(toBuffers(randomBytes(dataShards, 2000))), 10000)
+	if _, ok := err.(StreamWriteError); !ok {
+		t.Errorf("expected StreamWriteError, got %v", err)
+	}
+	readers := toReaders(toBuffers(randomBytes(dataShards, 2000)))
+	readers[1] = &failingReader{r: readers[1], n: 1500}
+	err = r.(StreamReaderAtEncoder).JoinAt(context.Background(), make(memWriterAt, 10000), readers, 10000)
+	if se, ok := err.(StreamReadError); !ok || se.Stream != 1 {
+		t.Errorf("expected StreamReadError on stream 1, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = r.(StreamReaderAtEncoder).JoinAt(ctx, make(memWriterAt, 10000), toReaders(toBuffers(randomBytes(dataShards, 2000))), 10000)
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
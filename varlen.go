@@ -0,0 +1,164 @@
+package reedsolomon
+
+import (
+	"bytes"
+)
+
+// EncodeVarLen encodes parity like Encode, but data shards may be
+// shorter than the parity shards. Data shards are treated as if they
+// were extended with zeros to the length of the parity shards,
+// so the end of the data doesn't have to be padded.
+// Data shards can be nil, which is the same as all zeros.
+//
+// All parity shards must have the same length, which must be at least the
+// length of the longest data shard. Parity shards are overwritten.
+//
+// The lengths of the data shards are not stored in the parity,
+// so they should be recorded to get the original data shards back
+// with ReconstructVarLen.
+func (r *reedSolomon) EncodeVarLen(shards [][]byte) error {
+	size, err := r.checkVarLen(shards)
+	if err != nil {
+		return err
+	}
+	r.codeSomeShardsSparse(r.parity, shards[:r.DataShards], shards[r.DataShards:], size, r.o.skipZeroBlocks)
+	return nil
+}
+
+// VerifyVarLen returns true if the parity shards contain the right data
+// for data shards that may be shorter than the parity shards,
+// as encoded by EncodeVarLen. No data is modified.
+func (r *reedSolomon) VerifyVarLen(shards [][]byte) (bool, error) {
+	size, err := r.checkVarLen(shards)
+	if err != nil {
+		return false, err
+	}
+	outputs := make([][]byte, r.ParityShards)
+	for i := range outputs {
+		outputs[i] = r.o.alloc(size)
+		defer r.o.free(outputs[i])
+	}
+	r.codeSomeShardsSparse(r.parity, shards[:r.DataShards], outputs, size, r.o.skipZeroBlocks)
+	for i, calc := range outputs {
+		if !bytes.Equal(calc, shards[r.DataShards+i]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// checkVarLen checks the shards for EncodeVarLen and returns the size of the parity.
+func (r *reedSolomon) checkVarLen(shards [][]byte) (int, error) {
+	if len(shards) != r.Shards {
+		return 0, ErrTooFewShards
+	}
+	if r.ParityShards == 0 {
+		return 0, ErrInvShardNum
+	}
+	parity := shards[r.DataShards:]
+	if err := checkShards(parity, false); err != nil {
+		return 0, err
+	}
+	size := len(parity[0])
+	for _, shard := range shards[:r.DataShards] {
+		if len(shard) > size {
+			return 0, ErrShardSize
+		}
+	}
+	return size, nil
+}
+
+// ReconstructVarLen will recreate missing shards encoded with EncodeVarLen.
+//
+// You indicate that a shard is missing by setting it to nil or zero-length.
+// Present data shards may be shorter than the parity shards.
+// Present parity shards must all have the same length,
+// and if no parity shards are present, they get the length of the longest data shard.
+//
+// If 'lengths' is not nil, it must contain the original length of every data shard.
+// Reconstructed data shards are then returned with their original length,
+// present data shards must have their original length, and data shards with
+// length 0 are not treated as missing.
+// If 'lengths' is nil, reconstructed data shards have the length of the parity shards.
+//
+// If a missing shard has sufficient capacity it is used, otherwise
+// a new slice is allocated. Present shards are not modified.
+//
+// If there are too few shards to reconstruct the missing
+// ones, ErrTooFewShards will be returned.
+func (r *reedSolomon) ReconstructVarLen(shards [][]byte, lengths []int) error {
+	if len(shards) != r.Shards {
+		return ErrTooFewShards
+	}
+	if lengths != nil && len(lengths) != r.DataShards {
+		return ErrInvalidInput
+	}
+
+	// The size is the size of the parity.
+	size := shardSize(shards[r.DataShards:])
+	if size == 0 {
+		for i := 0; i < r.DataShards; i++ {
+			if len(shards[i]) > size {
+				size = len(shards[i])
+			}
+			if lengths != nil && lengths[i] > size {
+				size = lengths[i]
+			}
+		}
+	}
+	if size == 0 {
+		return ErrShardNoData
+	}
+	for i, shard := range shards {
+		if len(shard) > size || i >= r.DataShards && len(shard) != 0 && len(shard) != size {
+			return ErrShardSize
+		}
+		if i < r.DataShards && lengths != nil {
+			if lengths[i] < 0 || lengths[i] > size || len(shard) != 0 && len(shard) != lengths[i] {
+				return ErrShardSize
+			}
+		}
+	}
+
+	// known returns whether shard i is not missing.
+	known := func(i int) bool {
+		return len(shards[i]) != 0 || i < r.DataShards && lengths != nil && lengths[i] == 0
+	}
+	missing := false
+	for i := range shards {
+		if !known(i) {
+			missing = true
+		}
+	}
+	if !missing {
+		return nil
+	}
+
+	// Extend present data shards with zeros.
+	full := make([][]byte, r.Shards)
+	for i, shard := range shards {
+		switch {
+		case len(shard) == size:
+			full[i] = shard
+		case known(i):
+			full[i] = r.o.allocZero(size)
+			copy(full[i], shard)
+			defer r.o.free(full[i])
+		default:
+			full[i] = shard[:0]
+		}
+	}
+	if err := r.reconstruct(full, false); err != nil {
+		return err
+	}
+	for i := range shards {
+		if known(i) {
+			continue
+		}
+		shards[i] = full[i]
+		if i < r.DataShards && lengths != nil {
+			shards[i] = full[i][:lengths[i]]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package reedsolomon
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeVarLen(t *testing.T) {
+	const dataShards, parityShards = 6, 3
+	for _, size := range []int{1, 100, 5000, 70000} {
+		for _, opts := range append(testOpts(), []Option{WithSkipZeroBlocks(true)}) {
+			enc, err := New(dataShards, parityShards, testOptions(opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			shards := make([][]byte, dataShards+parityShards)
+			lengths := make([]int, dataShards)
+			for i := 0; i < dataShards; i++ {
+				lengths[i] = size - i*size/5
+				if lengths[i] < 0 {
+					lengths[i] = 0
+				}
+				if i == 2 {
+					lengths[i] = size
+				}
+				shards[i] = make([]byte, lengths[i])
+				fillRandom(shards[i])
+			}
+			for i := dataShards; i < len(shards); i++ {
+				shards[i] = make([]byte, size)
+				fillRandom(shards[i])
+			}
+			if err := enc.(VarLenEncoder).EncodeVarLen(shards); err != nil {
+				t.Fatal(err)
+			}
+
+			// Compare with padded data.
+			padded := make([][]byte, len(shards))
+			for i := range shards {
+				padded[i] = make([]byte, size)
+				copy(padded[i], shards[i])
+			}
+			ok, err := enc.Verify(padded)
+			if err != nil || !ok {
+				t.Fatal("padded verification failed", err)
+			}
+			ok, err = enc.(VarLenEncoder).VerifyVarLen(shards)
+			if err != nil || !ok {
+				t.Fatal("verification failed", err)
+			}
+
+			want := make([][]byte, len(shards))
+			copy(want, shards)
+			shards[0], shards[3], shards[dataShards] = nil, nil, nil
+			if err := enc.(VarLenEncoder).ReconstructVarLen(shards, lengths); err != nil {
+				t.Fatal(err)
+			}
+			for i := range shards {
+				if !bytes.Equal(shards[i], want[i]) {
+					t.Fatalf("size %d: shard %d mismatch, got len %d, want %d", size, i, len(shards[i]), len(want[i]))
+				}
+			}
+
+			// Without lengths, data shards get the full size.
+			shards[1], shards[dataShards+2] = nil, nil
+			if err := enc.(VarLenEncoder).ReconstructVarLen(shards, nil); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(shards[1], padded[1]) || !bytes.Equal(shards[dataShards+2], want[dataShards+2]) {
+				t.Fatalf("size %d: reconstructed mismatch", size)
+			}
+			shards[1] = want[1]
+
+			shards[2] = append([]byte(nil), shards[2]...)
+			shards[2][0]++
+			ok, err = enc.(VarLenEncoder).VerifyVarLen(shards)
+			if err != nil || ok {
+				t.Fatal("verification should fail", err)
+			}
+		}
+	}
+
+	enc, err := New(dataShards, parityShards, testOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := make([][]byte, dataShards+parityShards)
+	for i := dataShards; i < len(shards); i++ {
+		shards[i] = make([]byte, 10)
+	}
+	shards[0] = make([]byte, 11)
+	if err := enc.(VarLenEncoder).EncodeVarLen(shards); err != ErrShardSize {
+		t.Errorf("expected %v, got %v", ErrShardSize, err)
+	}
+	shards[0] = nil
+	if err := enc.(VarLenEncoder).EncodeVarLen(shards); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.(VarLenEncoder).ReconstructVarLen(shards, []int{1}); err != ErrInvalidInput {
+		t.Errorf("expected %v, got %v", ErrInvalidInput, err)
+	}
+	if err := enc.(VarLenEncoder).ReconstructVarLen(make([][]byte, dataShards+parityShards), nil); err != ErrShardNoData {
+		t.Errorf("expected %v, got %v", ErrShardNoData, err)
+	}
+}